@@ -0,0 +1,162 @@
+package mim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ONSdigital/dp-mongodb-in-memory/download"
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// Cluster represents a set of running MongoDB servers configured as members of a single replica set.
+type Cluster struct {
+	members []*Server
+	size    int
+	replSet string
+	version *download.Version
+	// clusterRole is "configsvr" or "shardsvr" when the replica set is part of a sharded cluster
+	clusterRole string
+}
+
+// ClusterOption defines the template function for defining options that may be used to configure the cluster
+// The options available are given by the exported variables: WithMembers, WithReplicaSetName
+type ClusterOption func(*Cluster)
+
+var (
+	WithMembers        = func(n int) ClusterOption { return func(c *Cluster) { c.size = n } }
+	WithReplicaSetName = func(n string) ClusterOption { return func(c *Cluster) { c.replSet = n } }
+)
+
+// StartCluster runs a replica set of MongoDB servers of the given version, with 0 or more options as defined:
+// WithMembers, WithReplicaSetName
+//
+// If no WithMembers option is provided, the replica set is started with 3 members
+// If no WithReplicaSetName option is provided, the replica set is named "rs0"
+// Each member uses a random free port and its own temporary database directory
+// The cluster is returned once a primary has been elected
+func StartCluster(ctx context.Context, version string, co ...ClusterOption) (*Cluster, error) {
+	cluster := &Cluster{
+		size:    3,
+		replSet: "rs0",
+	}
+	for _, o := range co {
+		o(cluster)
+	}
+
+	if cluster.size < 1 {
+		return nil, fmt.Errorf("invalid number of members for the cluster: %d", cluster.size)
+	}
+	if cluster.replSet == "" {
+		return nil, errors.New("a replica set name is required for the cluster")
+	}
+
+	binPath, resolved, err := getOrDownloadBinPath(ctx, version, "")
+	if err != nil {
+		log.Fatal(ctx, "Could not find mongodb", err)
+		return nil, err
+	}
+	cluster.version = resolved
+
+	if err = cluster.start(ctx, binPath); err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, fmt.Sprintf("mongod cluster started up with the following configuration: %s", cluster))
+
+	return cluster, nil
+}
+
+// start runs the members of the cluster using the mongod binary found at binPath,
+// initiates them as a replica set and waits for a primary to be elected
+func (c *Cluster) start(ctx context.Context, binPath string) error {
+	for i := 0; i < c.size; i++ {
+		member := &Server{
+			minMongoLogLvl: LogDebug,
+			replSet:        c.replSet,
+			clusterRole:    c.clusterRole,
+			version:        c.version,
+		}
+		if err := member.start(ctx, binPath); err != nil {
+			member.Stop(ctx)
+			c.Stop(ctx)
+			return err
+		}
+		c.members = append(c.members, member)
+	}
+
+	if err := initiateReplicaSet(ctx, c.replSet, c.members); err != nil {
+		log.Error(ctx, "Could not initiate the replica set", err, log.Data{"replicaSet": c.replSet})
+		c.Stop(ctx)
+		return err
+	}
+
+	if _, err := waitForPrimary(ctx, c.replSet, c.members); err != nil {
+		log.Error(ctx, "No primary elected in the replica set", err, log.Data{"replicaSet": c.replSet})
+		c.Stop(ctx)
+		return err
+	}
+
+	return nil
+}
+
+// Stop kills every member of the cluster.
+func (c *Cluster) Stop(ctx context.Context) {
+	for _, m := range c.members {
+		m.Stop(ctx)
+	}
+}
+
+// Shutdown stops every member of the cluster gracefully, as Server.Shutdown does,
+// and returns any error found
+func (c *Cluster) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, m := range c.members {
+		if err := m.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Members returns the servers that make up the cluster
+func (c *Cluster) Members() []*Server {
+	return c.members
+}
+
+// ReplicaSet returns the Replica Set name being used by the cluster
+func (c *Cluster) ReplicaSet() string {
+	return c.replSet
+}
+
+// URI returns a mongodb:// URI with the seed list of all the members of the cluster
+func (c *Cluster) URI() string {
+	return fmt.Sprintf("mongodb://%s/?replicaSet=%s", c.hosts(), c.replSet)
+}
+
+// hosts returns the comma separated list of the members' host:port
+func (c *Cluster) hosts() string {
+	hosts := make([]string, 0, len(c.members))
+	for _, m := range c.members {
+		hosts = append(hosts, fmt.Sprintf("localhost:%d", m.Port()))
+	}
+	return strings.Join(hosts, ",")
+}
+
+// Primary returns the member of the cluster that is currently the writable primary.
+// If there is no primary (e.g. an election is in progress) it keeps polling the members
+// until one is found or the context is done, in which case an *ElectionTimeoutError is returned
+func (c *Cluster) Primary(ctx context.Context) (*Server, error) {
+	return waitForPrimary(ctx, c.replSet, c.members)
+}
+
+func (c *Cluster) String() string {
+	buf := new(strings.Builder)
+	_, _ = fmt.Fprintf(buf, "replica set name: %s;", c.replSet)
+	for i, m := range c.members {
+		_, _ = fmt.Fprintf(buf, " member %d listening on: localhost:%d using DB directory: %s;", i, m.Port(), m.DBdir())
+	}
+
+	return buf.String()
+}
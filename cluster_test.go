@@ -0,0 +1,119 @@
+package mim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+func TestStartCluster(t *testing.T) {
+	versions := []string{"4.4.8", "5.0.2"}
+	testCtx := context.Background()
+
+	for _, version := range versions {
+		Convey("Given the version "+version, t, func() {
+
+			Convey("When the StartCluster method is called with 3 members", func() {
+				cluster, err := StartCluster(testCtx, version, WithMembers(3))
+				defer cluster.Stop(testCtx)
+
+				Convey("Then no error is returned", func() {
+					So(err, ShouldBeNil)
+					Convey("And 3 mongod processes have run as members of the same replica set", func() {
+						So(cluster, ShouldNotBeNil)
+						So(cluster.ReplicaSet(), ShouldEqual, "rs0")
+						So(cluster.Members(), ShouldHaveLength, 3)
+
+						ports := map[int]bool{}
+						dirs := map[string]bool{}
+						for _, m := range cluster.Members() {
+							So(m.ReplicaSet(), ShouldEqual, "rs0")
+							So(m.watcherCmd, ShouldNotBeNil)
+							ports[m.Port()] = true
+							dirs[m.DBdir()] = true
+						}
+						So(ports, ShouldHaveLength, 3)
+						So(dirs, ShouldHaveLength, 3)
+
+						Convey("And a primary is elected", func() {
+							ctx, cancel := context.WithTimeout(testCtx, 30*time.Second)
+							defer cancel()
+
+							primary, err := cluster.Primary(ctx)
+							So(err, ShouldBeNil)
+							So(cluster.Members(), ShouldContain, primary)
+
+							Convey("And the cluster accepts majority writes and secondary reads", func() {
+								client, err := mongo.Connect(testCtx, options.Client().ApplyURI(cluster.URI()))
+								So(err, ShouldBeNil)
+								defer client.Disconnect(testCtx)
+
+								coll := client.Database("test").Collection("test", options.Collection().SetWriteConcern(writeconcern.Majority()))
+								_, err = coll.InsertOne(ctx, bson.D{{Key: "a", Value: 1}})
+								So(err, ShouldBeNil)
+
+								secondary := client.Database("test").Collection("test", options.Collection().SetReadPreference(readpref.Secondary()))
+								So(secondary.FindOne(ctx, bson.D{}).Err(), ShouldBeNil)
+							})
+						})
+					})
+				})
+			})
+
+			Convey("When the StartCluster method is called with a replica set name", func() {
+				cluster, err := StartCluster(testCtx, version, WithMembers(1), WithReplicaSetName("my-replica-set"))
+				defer cluster.Stop(testCtx)
+
+				Convey("Then no error is returned", func() {
+					So(err, ShouldBeNil)
+					Convey("And the replica set has the given name", func() {
+						So(cluster.ReplicaSet(), ShouldEqual, "my-replica-set")
+						So(cluster.Members(), ShouldHaveLength, 1)
+						So(cluster.Members()[0].ReplicaSet(), ShouldEqual, "my-replica-set")
+					})
+				})
+			})
+		})
+	}
+
+	Convey("When the StartCluster method is called with no members", t, func() {
+		cluster, err := StartCluster(testCtx, "5.0.2", WithMembers(0))
+
+		Convey("Then an error is returned", func() {
+			So(cluster, ShouldBeNil)
+			So(err, ShouldBeError, "invalid number of members for the cluster: 0")
+		})
+	})
+}
+
+func TestClusterConfiguration(t *testing.T) {
+	Convey("Given a cluster with 3 members", t, func() {
+		cluster := &Cluster{
+			replSet: "rs0",
+			members: []*Server{{port: 27017}, {port: 27018}, {port: 27019}},
+		}
+
+		Convey("Then URI returns a seed list of all the members", func() {
+			So(cluster.URI(), ShouldEqual, "mongodb://localhost:27017,localhost:27018,localhost:27019/?replicaSet=rs0")
+		})
+
+		Convey("Then replicaSetConfig builds a document with all the members", func() {
+			So(replicaSetConfig(cluster.replSet, cluster.members), ShouldResemble, bson.D{
+				{Key: "_id", Value: "rs0"},
+				{Key: "members", Value: bson.A{
+					bson.D{{Key: "_id", Value: 0}, {Key: "host", Value: "localhost:27017"}},
+					bson.D{{Key: "_id", Value: 1}, {Key: "host", Value: "localhost:27018"}},
+					bson.D{{Key: "_id", Value: 2}, {Key: "host", Value: "localhost:27019"}},
+				}},
+			})
+		})
+	})
+}
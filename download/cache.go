@@ -0,0 +1,209 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// cacheEntryRegexp splits the name of a cache entry, e.g. mongodb-linux-x86_64-ubuntu2004-5.0.2.tgz
+// or mongodb-database-tools-macos-arm64-100.9.4.zip, into its platform and version
+var cacheEntryRegexp = regexp.MustCompile(`^mongodb-(.+?)-(\d+\.\d+\.\d+(?:-[0-9A-Za-z.]+)?)\.(?:tgz|zip)$`)
+
+// Cache gives access to the MongoDB binaries stored in a cache directory
+type Cache struct {
+	dir string
+}
+
+// CacheEntry describes the binaries of a MongoDB tarball stored in the cache
+type CacheEntry struct {
+	// Name is the name of the tarball the binaries were extracted from
+	Name string
+	// Path is the directory holding the binaries
+	Path string
+	// Version is the MongoDB version, e.g. 5.0.2
+	Version string
+	// Platform is the platform the binaries were built for, e.g. linux-x86_64-ubuntu2004
+	Platform string
+	// Size is the total size of the binaries in bytes
+	Size int64
+	// LastUsed is the last time the binaries were installed or found in the cache by GetMongoDB
+	LastUsed time.Time
+}
+
+// PruneOption defines the template function for defining options that may be used to prune the cache
+// The options available are given by the exported variables: WithMaxAge, WithMaxEntries, WithMaxSize
+type PruneOption func(*pruneConfig)
+
+type pruneConfig struct {
+	maxAge     time.Duration
+	maxEntries int
+	maxSize    int64
+}
+
+var (
+	WithMaxAge     = func(d time.Duration) PruneOption { return func(c *pruneConfig) { c.maxAge = d } }
+	WithMaxEntries = func(n int) PruneOption { return func(c *pruneConfig) { c.maxEntries = n } }
+	WithMaxSize    = func(bytes int64) PruneOption { return func(c *pruneConfig) { c.maxSize = bytes } }
+)
+
+// NewCache returns the cache used by GetMongoDB, in the OS cache path
+func NewCache() (*Cache, error) {
+	cacheHome, err := defaultBaseCachePath()
+	if err != nil {
+		return nil, err
+	}
+	return NewCacheAt(path.Join(cacheHome, folderName)), nil
+}
+
+// NewCacheAt returns the cache stored in the given directory
+func NewCacheAt(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Dir returns the directory the cache is stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// List returns the entries of the cache, most recently used first
+func (c *Cache) List() ([]CacheEntry, error) {
+	dirs, err := afs.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []CacheEntry
+	for _, dir := range dirs {
+		match := cacheEntryRegexp.FindStringSubmatch(dir.Name())
+		if !dir.IsDir() || match == nil {
+			continue
+		}
+
+		entry := CacheEntry{
+			Name:     dir.Name(),
+			Path:     path.Join(c.dir, dir.Name()),
+			Platform: match[1],
+			Version:  match[2],
+			LastUsed: dir.ModTime(),
+		}
+		files, err := afs.ReadDir(entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			entry.Size += f.Size()
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// Manifest returns the manifest recorded when the entry was installed, or ErrNoManifest if there is none
+func (c *Cache) Manifest(entry CacheEntry) (*Manifest, error) {
+	return readManifest(entry.Path)
+}
+
+// Verify checks the binaries of the entry against the checksums recorded in its manifest when they were installed.
+// ErrNoManifest is returned if there is no manifest
+func (c *Cache) Verify(entry CacheEntry) error {
+	if err := verifyEntry(entry.Path); err != nil {
+		return fmt.Errorf("cache entry %s: %w", entry.Name, err)
+	}
+	return nil
+}
+
+// Remove deletes the entry from the cache.
+// It waits for any process installing the entry at the same time until the context is done
+func (c *Cache) Remove(ctx context.Context, entry CacheEntry) error {
+	unlockEntry, err := lockCacheEntry(ctx, path.Join(entry.Path, "mongod"))
+	if err != nil {
+		return err
+	}
+	defer unlockEntry()
+
+	log.Info(ctx, "Removing MongoDB from cache", log.Data{"dir": entry.Path})
+	return afs.RemoveAll(entry.Path)
+}
+
+// RemoveCorrupt deletes the entries whose binaries do not match the checksums recorded at install time,
+// and returns them. Entries without a manifest are kept
+func (c *Cache) RemoveCorrupt(ctx context.Context) ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []CacheEntry
+	for _, entry := range entries {
+		verifyErr := c.Verify(entry)
+		if verifyErr == nil || errors.Is(verifyErr, ErrNoManifest) {
+			continue
+		}
+		log.Warn(ctx, "Corrupt MongoDB cache entry", log.Data{"dir": entry.Path, "error": verifyErr.Error()})
+		if err = c.Remove(ctx, entry); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// Prune deletes the least recently used entries of the cache, with 0 or more options as defined:
+// WithMaxAge, WithMaxEntries, WithMaxSize
+//
+// WithMaxAge removes the entries not used for longer than the given duration
+// WithMaxEntries removes the entries beyond the given number of most recently used ones
+// WithMaxSize removes the least recently used entries until the total size is within the given number of bytes
+// A zero value disables the corresponding limit. The entries removed are returned
+func (c *Cache) Prune(ctx context.Context, opts ...PruneOption) ([]CacheEntry, error) {
+	cfg := &pruneConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []CacheEntry
+	var kept int
+	var keptSize int64
+	for _, entry := range entries {
+		expired := cfg.maxAge > 0 && time.Since(entry.LastUsed) > cfg.maxAge
+		tooMany := cfg.maxEntries > 0 && kept >= cfg.maxEntries
+		tooBig := cfg.maxSize > 0 && keptSize+entry.Size > cfg.maxSize
+		if !expired && !tooMany && !tooBig {
+			kept++
+			keptSize += entry.Size
+			continue
+		}
+
+		if err = c.Remove(ctx, entry); err != nil {
+			return removed, err
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// touchCacheEntry records that the entry holding the given binary has just been used
+func touchCacheEntry(binPath string) error {
+	now := time.Now()
+	return afs.Chtimes(path.Dir(binPath), now, now)
+}
@@ -0,0 +1,148 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestCache(t *testing.T) {
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a cache holding several entries", t, func() {
+		dir, _ := afs.TempDir("", "")
+		cache := NewCacheAt(dir)
+		now := time.Now()
+
+		// addEntry stores a mongod binary of the given size in the cache, last used the given time ago
+		addEntry := func(name string, size int, age time.Duration) string {
+			entryPath := path.Join(dir, name)
+			binPath := path.Join(entryPath, "mongod")
+			So(afs.MkdirAll(entryPath, 0755), ShouldBeNil)
+			So(afs.WriteFile(binPath, make([]byte, size), 0755), ShouldBeNil)
+			checksum, err := sha256Sum(binPath, io.Discard)
+			So(err, ShouldBeNil)
+			So(writeManifest(entryPath, &Manifest{Binaries: map[string]string{"mongod": checksum}}), ShouldBeNil)
+			So(afs.Chtimes(entryPath, now.Add(-age), now.Add(-age)), ShouldBeNil)
+			return entryPath
+		}
+		addEntry("mongodb-linux-x86_64-ubuntu2004-5.0.2.tgz", 1000, time.Hour)
+		addEntry("mongodb-linux-x86_64-ubuntu2004-4.4.8.tgz", 2000, 48*time.Hour)
+		addEntry("mongodb-macos-x86_64-6.0.1.tgz", 3000, time.Minute)
+		So(afs.WriteFile(path.Join(dir, "mongodb-macos-x86_64-6.0.1.tgz.lock"), nil, 0644), ShouldBeNil)
+		So(afs.MkdirAll(path.Join(dir, "unrelated"), 0755), ShouldBeNil)
+
+		Convey("When the entries are listed", func() {
+			entries, err := cache.List()
+
+			Convey("Then they are returned with their details, most recently used first", func() {
+				So(err, ShouldBeNil)
+				So(entries, ShouldHaveLength, 3)
+				So(entries[0].Name, ShouldEqual, "mongodb-macos-x86_64-6.0.1.tgz")
+				So(entries[0].Version, ShouldEqual, "6.0.1")
+				So(entries[0].Platform, ShouldEqual, "macos-x86_64")
+				So(entries[0].Path, ShouldEqual, path.Join(dir, "mongodb-macos-x86_64-6.0.1.tgz"))
+				So(entries[0].Size, ShouldBeGreaterThan, 3000)
+				So(entries[0].LastUsed, ShouldHappenWithin, time.Second, now.Add(-time.Minute))
+				So(entries[1].Version, ShouldEqual, "5.0.2")
+				So(entries[1].Platform, ShouldEqual, "linux-x86_64-ubuntu2004")
+				So(entries[2].Version, ShouldEqual, "4.4.8")
+			})
+		})
+
+		Convey("When an entry is verified", func() {
+			entries, _ := cache.List()
+
+			Convey("Then no error is returned if its binaries are intact", func() {
+				So(cache.Verify(entries[0]), ShouldBeNil)
+			})
+
+			Convey("Then an error is returned if a binary has changed", func() {
+				So(afs.WriteFile(path.Join(entries[0].Path, "mongod"), []byte("truncated"), 0755), ShouldBeNil)
+				var checksumErr *ChecksumMismatchError
+				So(errors.As(cache.Verify(entries[0]), &checksumErr), ShouldBeTrue)
+			})
+
+			Convey("Then ErrNoManifest is wrapped if there is no manifest", func() {
+				So(afs.Remove(path.Join(entries[0].Path, manifestFileName)), ShouldBeNil)
+				So(errors.Is(cache.Verify(entries[0]), ErrNoManifest), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the corrupt entries are removed", func() {
+			entries, _ := cache.List()
+			So(afs.WriteFile(path.Join(entries[1].Path, "mongod"), []byte("truncated"), 0755), ShouldBeNil)
+			So(afs.Remove(path.Join(entries[2].Path, manifestFileName)), ShouldBeNil)
+
+			removed, err := cache.RemoveCorrupt(testCtx)
+
+			Convey("Then only the entries not matching their checksum are removed", func() {
+				So(err, ShouldBeNil)
+				So(removed, ShouldHaveLength, 1)
+				So(removed[0].Version, ShouldEqual, "5.0.2")
+				So(versionsOf(cache), ShouldResemble, []string{"6.0.1", "4.4.8"})
+			})
+		})
+
+		Convey("When the cache is pruned by age", func() {
+			removed, err := cache.Prune(testCtx, WithMaxAge(24*time.Hour))
+
+			Convey("Then the entries not used recently are removed", func() {
+				So(err, ShouldBeNil)
+				So(removed, ShouldHaveLength, 1)
+				So(versionsOf(cache), ShouldResemble, []string{"6.0.1", "5.0.2"})
+			})
+		})
+
+		Convey("When the cache is pruned by number of entries", func() {
+			removed, err := cache.Prune(testCtx, WithMaxEntries(1))
+
+			Convey("Then only the most recently used entries are kept", func() {
+				So(err, ShouldBeNil)
+				So(removed, ShouldHaveLength, 2)
+				So(versionsOf(cache), ShouldResemble, []string{"6.0.1"})
+			})
+		})
+
+		Convey("When the cache is pruned by total size", func() {
+			removed, err := cache.Prune(testCtx, WithMaxSize(5000))
+
+			Convey("Then the least recently used entries are removed until the size is within the limit", func() {
+				So(err, ShouldBeNil)
+				So(removed, ShouldHaveLength, 1)
+				So(versionsOf(cache), ShouldResemble, []string{"6.0.1", "5.0.2"})
+			})
+		})
+
+		Convey("When the cache is pruned without limits", func() {
+			removed, err := cache.Prune(testCtx)
+
+			Convey("Then nothing is removed", func() {
+				So(err, ShouldBeNil)
+				So(removed, ShouldBeEmpty)
+				So(versionsOf(cache), ShouldHaveLength, 3)
+			})
+		})
+	})
+}
+
+// versionsOf returns the versions of the entries of the cache, most recently used first
+func versionsOf(cache *Cache) []string {
+	entries, err := cache.List()
+	So(err, ShouldBeNil)
+
+	versions := make([]string, 0, len(entries))
+	for _, e := range entries {
+		versions = append(versions, e.Version)
+	}
+	return versions
+}
@@ -2,9 +2,13 @@ package download
 
 import (
 	"context"
-	"net/url"
+	"fmt"
+	"net/http"
 	"os"
 	"path"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/ONSdigital/log.go/v2/log"
 )
@@ -12,14 +16,17 @@ import (
 // folderName is the name of the folder we will be saving mongod in the cache path
 const folderName = "dp-mongodb-in-memory"
 
-// getDownloadUrl returns the mongodb download url for a given version
-var getDownloadUrl = func(v Version) (string, error) {
+// OfflineEnv is the environment variable that enables the offline mode when set to a true value (e.g. "true" or "1")
+const OfflineEnv = "MIM_OFFLINE"
+
+// getArtifactPath returns the path to the mongodb tarball for a given version, relative to the download source
+var getArtifactPath = func(v Version) (string, error) {
 	spec, err := MakeDownloadSpec(v)
 	if err != nil {
 		return "", err
 	}
 
-	return spec.GetDownloadURL()
+	return spec.GetArtifactPath()
 }
 
 // getEnv returns the value of an environment variable
@@ -31,55 +38,259 @@ var getEnv = func(key string) string {
 type Config struct {
 	// The MongoDB version we are using
 	mongoVersion Version
-	// The URL where the required mongodb tarball can be downloaded from
-	mongoUrl string
+	// The path to the required mongodb tarball, relative to the source
+	artifact string
+	// The source the mongodb tarball, checksum and signature are downloaded from
+	source Source
+	// The source the MongoDB public keys are downloaded from
+	keySource Source
+	// The source the MongoDB release manifest is downloaded from
+	releasesSource Source
+	// How long the cached MongoDB release manifest is used before being downloaded again
+	releasesTTL time.Duration
+	// The client used by the HTTP sources created by NewConfig
+	httpClient *http.Client
 	// The path where the mongod executable can be found if previously downloaded
 	cachePath string
+	// Whether downloads are disallowed, so that only the cache is used
+	offline bool
+	// Whether the cached binaries are verified against their manifest before use
+	verifyCache bool
+	// The function the progress of the installation is reported to, if any
+	progress ProgressFunc
+	// The platform the binaries are downloaded for, if not the current one
+	target *platformTarget
+	// The directory the binaries are cached in, if not the default one
+	cacheRoot string
+	// Whether the key downloaded for a release series with no pinned key is trusted
+	trustOnFirstUse bool
+	// The executables extracted from the tarball in addition to mongod and mongos, and mongosh if requested
+	tools []string
+	// The source the mongosh archive and its signature are downloaded from
+	mongoshSource Source
+	// The version of mongosh installed if requested, if not DefaultMongoshVersion
+	mongoshVersion string
+	// The path to the mongosh archive, relative to the mongosh source, if mongosh is requested
+	mongoshArtifactPath string
+}
+
+// platformTarget is a platform as given to NewDownloadSpec
+type platformTarget struct {
+	platform string
+	arch     string
+	osName   string
 }
 
-// NewConfig creates the config values for the given version.
+// ConfigOption defines the template function for defining options that may be used to configure the download
+// The options available are given by the exported variables: WithOffline, WithSource, WithKeySource, WithHTTPClient,
+// WithProgress, WithProgressChan, WithVerifyCache, WithReleasesSource, WithReleasesTTL, WithPlatform, WithCacheRoot,
+// WithTrustOnFirstUse, WithTools, WithMongoshVersion
+type ConfigOption func(*Config)
+
+var (
+	WithOffline = func(o bool) ConfigOption { return func(cfg *Config) { cfg.offline = o } }
+	WithSource  = func(src Source) ConfigOption {
+		return func(cfg *Config) {
+			cfg.source = src
+			cfg.keySource = src
+			cfg.releasesSource = src
+			cfg.mongoshSource = src
+		}
+	}
+	WithKeySource    = func(src Source) ConfigOption { return func(cfg *Config) { cfg.keySource = src } }
+	WithHTTPClient   = func(c *http.Client) ConfigOption { return func(cfg *Config) { cfg.httpClient = c } }
+	WithProgress     = func(f ProgressFunc) ConfigOption { return func(cfg *Config) { cfg.progress = f } }
+	WithProgressChan = func(ch chan<- Progress) ConfigOption {
+		return func(cfg *Config) {
+			cfg.progress = func(p Progress) {
+				select {
+				case ch <- p:
+				default:
+				}
+			}
+		}
+	}
+	WithVerifyCache    = func(v bool) ConfigOption { return func(cfg *Config) { cfg.verifyCache = v } }
+	WithReleasesSource = func(src Source) ConfigOption { return func(cfg *Config) { cfg.releasesSource = src } }
+	WithReleasesTTL    = func(ttl time.Duration) ConfigOption { return func(cfg *Config) { cfg.releasesTTL = ttl } }
+	WithPlatform       = func(platform, arch, osName string) ConfigOption {
+		return func(cfg *Config) { cfg.target = &platformTarget{platform: platform, arch: arch, osName: osName} }
+	}
+	WithCacheRoot       = func(dir string) ConfigOption { return func(cfg *Config) { cfg.cacheRoot = dir } }
+	WithTrustOnFirstUse = func(t bool) ConfigOption { return func(cfg *Config) { cfg.trustOnFirstUse = t } }
+	WithTools           = func(names ...string) ConfigOption {
+		return func(cfg *Config) { cfg.tools = append(cfg.tools, names...) }
+	}
+	WithMongoshVersion = func(version string) ConfigOption { return func(cfg *Config) { cfg.mongoshVersion = version } }
+)
+
+// NewConfig creates the config values for the given version, with 0 or more options as defined:
+// WithOffline, WithSource, WithKeySource, WithHTTPClient, WithProgress, WithProgressChan, WithVerifyCache,
+// WithReleasesSource, WithReleasesTTL, WithPlatform, WithCacheRoot, WithTrustOnFirstUse, WithTools
+// The version is resolved as described by ResolveVersion, e.g. "7.0", "latest" or ">=6.0 <7.0".
 // It will identify the appropriate mongodb artifact
 // and the cache path based on the current OS
-func NewConfig(ctx context.Context, mongoVersionStr string) (*Config, error) {
-	version, versionErr := NewVersion(mongoVersionStr)
-	if versionErr != nil {
-		return nil, versionErr
+//
+// The offline mode is enabled if the MIM_OFFLINE environment variable is set to a true value,
+// unless the WithOffline option says otherwise
+// The artifacts are downloaded from the MongoDB websites, or from the mirror given in the MIM_DOWNLOAD_MIRROR
+// environment variable if set. WithSource sets the source of the artifacts, the public keys and the release manifest,
+// WithKeySource the source of the public keys only, and WithReleasesSource the source of the release manifest only
+// WithReleasesTTL sets how long the cached release manifest is used before being downloaded again (1 day by default)
+// WithHTTPClient sets the client used to download from the MongoDB websites or the HTTP mirror, e.g. to configure
+// a proxy or TLS. If not provided, a default client honouring the proxy environment variables is used
+// WithProgress and WithProgressChan set a function or a channel the progress of the installation is reported to.
+// The installation never waits for the channel: a Progress is dropped if the channel is not ready to receive it,
+// so a buffered channel is advised.
+// If neither is provided and stderr is a terminal, a progress line is rendered on stderr
+// The verification of the cached binaries against the manifest written when they were installed is enabled
+// if the MIM_VERIFY_CACHE environment variable is set to a true value, unless the WithVerifyCache option says otherwise
+// WithPlatform sets the platform, architecture and Linux build the binaries are downloaded for, as given to
+// NewDownloadSpec, instead of the current system (e.g. to warm a cache for other targets)
+// WithCacheRoot sets the directory the binaries are cached in, instead of the dp-mongodb-in-memory folder
+// of the OS cache path
+// Releases are verified with the key pinned for their series, bundled in the package or else downloaded from the key
+// source. For series with no pinned key, the downloaded key is trusted on first use and kept in the cache if the
+// MIM_TRUST_ON_FIRST_USE environment variable is set to a true value, unless the WithTrustOnFirstUse option says
+// otherwise; an UnknownKeyError is returned if not
+// WithTools adds executables to install along with mongod and mongos: the legacy "mongo" shell shipped in the tarball
+// up to MongoDB 5.0, and "mongosh", downloaded from its own archive. They are cached together as one bundle
+// per version, and found with ToolPath. An UnsupportedToolError is returned for any other tool: the MongoDB
+// Database Tools are installed with NewToolsConfig and GetDatabaseTools
+// WithMongoshVersion sets the concrete version of mongosh installed, DefaultMongoshVersion if not provided.
+// Its archive is downloaded from the source given by WithSource, the mirror or else DefaultMongoshURL, and verified
+// with the key pinned for "mongosh", downloaded from the key source as static/pgp/mongosh.asc
+func NewConfig(ctx context.Context, mongoVersionStr string, opts ...ConfigOption) (*Config, error) {
+	cfg, err := newBaseConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := cfg.resolveVersion(ctx, mongoVersionStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cfg.checkTools(*version); err != nil {
+		return nil, err
+	}
+	if slices.Contains(cfg.tools, "mongosh") {
+		if cfg.mongoshArtifactPath, err = cfg.mongoshArtifact(); err != nil {
+			return nil, err
+		}
 	}
 
-	downloadUrl, err := getDownloadUrl(*version)
+	artifact, err := cfg.artifactPath(*version)
 	if err != nil {
 		return nil, err
 	}
 
-	cachePath, err := buildBinCachePath(ctx, downloadUrl)
+	cachePath, err := cfg.buildBinCachePath(ctx, artifact)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Config{
-		mongoVersion: *version,
-		mongoUrl:     downloadUrl,
-		cachePath:    cachePath,
-	}, nil
+	cfg.mongoVersion = *version
+	cfg.artifact = artifact
+	cfg.cachePath = cachePath
+	return cfg, nil
 }
 
-// buildBinCachePath returns the full path to where the mongod binary should be located.
-func buildBinCachePath(ctx context.Context, downloadUrl string) (string, error) {
-	cacheHome, err := defaultBaseCachePath()
+// newBaseConfig creates the config values that do not depend on the version, with the given options
+// and the environment variables
+func newBaseConfig(ctx context.Context, opts ...ConfigOption) (*Config, error) {
+	offline, _ := strconv.ParseBool(getEnv(OfflineEnv))
+	verifyCache, _ := strconv.ParseBool(getEnv(VerifyCacheEnv))
+	trustOnFirstUse, _ := strconv.ParseBool(getEnv(TrustOnFirstUseEnv))
+
+	cfg := &Config{
+		offline:         offline,
+		verifyCache:     verifyCache,
+		releasesTTL:     defaultReleasesTTL,
+		trustOnFirstUse: trustOnFirstUse,
+	}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	if cfg.progress == nil && stderrIsTerminal() {
+		cfg.progress = NewProgressRenderer(os.Stderr, progressRenderInterval)
+	}
+
+	var mirror Source
+	if mirrorLocation := getEnv(MirrorEnv); mirrorLocation != "" {
+		var err error
+		mirror, err = NewSource(mirrorLocation, cfg.httpClient)
+		if err != nil {
+			log.Error(ctx, "invalid download mirror", err, log.Data{"mirror": mirrorLocation})
+			return nil, fmt.Errorf("invalid %s value: %w", MirrorEnv, err)
+		}
+	}
+
+	cfg.source = sourceOrDefault(cfg.source, mirror, DefaultDownloadURL, cfg.httpClient)
+	cfg.keySource = sourceOrDefault(cfg.keySource, mirror, DefaultKeyURL, cfg.httpClient)
+	cfg.releasesSource = sourceOrDefault(cfg.releasesSource, mirror, DefaultReleasesURL, cfg.httpClient)
+	cfg.mongoshSource = sourceOrDefault(cfg.mongoshSource, mirror, DefaultMongoshURL, cfg.httpClient)
+
+	return cfg, nil
+}
+
+// sourceOrDefault returns the given source if any, otherwise the mirror if any,
+// otherwise an HTTP source for the default URL
+func sourceOrDefault(src, mirror Source, defaultURL string, client *http.Client) Source {
+	switch {
+	case src != nil:
+		return src
+	case mirror != nil:
+		return mirror
+	default:
+		return NewHTTPSource(defaultURL, client)
+	}
+}
+
+// Version returns the MongoDB version the config is for, once resolved
+func (cfg *Config) Version() Version {
+	return cfg.mongoVersion
+}
+
+// artifactPath returns the path to the mongodb tarball for a given version, relative to the download source,
+// for the target platform if any or else the current one
+func (cfg *Config) artifactPath(v Version) (string, error) {
+	if cfg.target == nil {
+		return getArtifactPath(v)
+	}
+
+	spec, err := NewDownloadSpec(v, cfg.target.platform, cfg.target.arch, cfg.target.osName)
 	if err != nil {
-		log.Error(ctx, "cache directory not found", err)
 		return "", err
 	}
+	return spec.GetArtifactPath()
+}
 
-	urlParsed, err := url.Parse(downloadUrl)
+// buildBinCachePath returns the full path to where the mongod binary should be located.
+func (cfg *Config) buildBinCachePath(ctx context.Context, artifact string) (string, error) {
+	cacheDir, err := cfg.cacheDir()
 	if err != nil {
-		log.Error(ctx, "error parsing url", err, log.Data{"url": downloadUrl})
+		log.Error(ctx, "cache directory not found", err)
 		return "", err
 	}
 
-	dirname := path.Base(urlParsed.Path)
+	dirname := path.Base(artifact)
+
+	return path.Join(cacheDir, dirname, "mongod"), nil
+}
+
+// cacheDir returns the directory the binaries and the release manifest are cached in
+func (cfg *Config) cacheDir() (string, error) {
+	if cfg.cacheRoot != "" {
+		return cfg.cacheRoot, nil
+	}
 
-	return path.Join(cacheHome, folderName, dirname, "mongod"), nil
+	cacheHome, err := defaultBaseCachePath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(cacheHome, folderName), nil
 }
 
 // defaultBaseCachePath finds the OS cache path.
@@ -107,12 +318,81 @@ func (cfg *Config) MongoPath() string {
 	return cfg.cachePath
 }
 
-// mongoSignatureUrl returns the url for the public signature file.
-func (cfg *Config) mongoSignatureUrl() string {
-	return cfg.mongoUrl + ".sig"
+// MongosPath returns the path to the mongos executable file
+func (cfg *Config) MongosPath() string {
+	return cfg.ToolPath("mongos")
+}
+
+// ToolPath returns the path to the named executable file of the bundle cached for the version, e.g. "mongos".
+// The executable is only there if it is mongod, mongos or one of the tools given by WithTools
+func (cfg *Config) ToolPath(name string) string {
+	return path.Join(path.Dir(cfg.cachePath), name)
+}
+
+// binaryNames returns the names of all the executables extracted from the tarball, without duplicates
+func (cfg *Config) binaryNames() []string {
+	names := make([]string, 0, len(binaries)+len(cfg.tools))
+	seen := make(map[string]bool, cap(names))
+	for _, name := range append(append([]string{}, binaries...), cfg.tools...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// requiredBinaries returns the names of the executables a cache entry must hold to be used, without duplicates:
+// mongod and the tools given by WithTools. mongos is always extracted, but it is only required if given by WithTools
+func (cfg *Config) requiredBinaries() []string {
+	names := make([]string, 0, 1+len(cfg.tools))
+	seen := make(map[string]bool, cap(names))
+	for _, name := range append([]string{"mongod"}, cfg.tools...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// checkTools returns an UnsupportedToolError if a tool given by WithTools is not shipped
+// with the MongoDB server tarball of the given version
+func (cfg *Config) checkTools(v Version) error {
+	for _, name := range cfg.tools {
+		if slices.Contains(binaries, name) || name == "mongosh" {
+			continue
+		}
+		if slices.Contains(databaseTools, name) {
+			return &UnsupportedToolError{Name: name, msg: "it is one of the MongoDB Database Tools, installed with NewToolsConfig and GetDatabaseTools"}
+		}
+		removedIn, ok := serverTools[name]
+		if !ok {
+			return &UnsupportedToolError{Name: name, msg: "it is not shipped with the MongoDB server"}
+		}
+		if v.IsGreaterOrEqual(removedIn.Major, removedIn.Minor, removedIn.Patch) {
+			return &UnsupportedToolError{Name: name, msg: "it is not shipped with MongoDB " + removedIn.String() + " and above"}
+		}
+	}
+	return nil
+}
+
+// binPaths returns the paths to all the executable files stored in the cache
+func (cfg *Config) binPaths() []string {
+	names := cfg.binaryNames()
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, cfg.ToolPath(name))
+	}
+	return paths
+}
+
+// mongoSignatureArtifact returns the path to the public signature file, relative to the source
+func (cfg *Config) mongoSignatureArtifact() string {
+	return cfg.artifact + ".sig"
 }
 
-// mongoChecksumUrl returns the url for the SHA256 file
-func (cfg *Config) mongoChecksumUrl() string {
-	return cfg.mongoUrl + ".sha256"
+// mongoChecksumArtifact returns the path to the SHA256 file, relative to the source
+func (cfg *Config) mongoChecksumArtifact() string {
+	return cfg.artifact + ".sha256"
 }
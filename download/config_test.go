@@ -3,13 +3,16 @@ package download
 import (
 	"context"
 	"errors"
+	"net/http"
+	"path"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
 func TestNewConfig(t *testing.T) {
-	var originalGetDownloadUrl = getDownloadUrl
+	var originalGetArtifactPath = getArtifactPath
+	var originalStderrIsTerminal = stderrIsTerminal
 	var originalGetEnv = getEnv
 	var originalGoOs = goOS
 	testCtx := context.Background()
@@ -30,7 +33,7 @@ func TestNewConfig(t *testing.T) {
 			})
 		})
 		Convey("With less than 2 periods", func() {
-			version := "1.2"
+			version := "1.a"
 			Convey("Then an error is returned", func() {
 				cfg, err := NewConfig(testCtx, version)
 				So(cfg, ShouldBeNil)
@@ -118,15 +121,15 @@ func TestNewConfig(t *testing.T) {
 	Convey("Given a valid MongoDB version", t, func() {
 		version := "5.0.2"
 
-		Convey("When the download url can be found", func() {
+		Convey("When the artifact path can be found", func() {
+			filename := "mongodb-linux-x86_64-ubuntu2004-" + version + ".tgz"
+			artifact := "linux/" + filename
 
-			Convey("And the url is valid", func() {
-				filename := "mongodb-linux-x86_64-ubuntu2004-" + version + ".tgz"
-				mongoUrl := "https://fastdl.mongodb.org/linux/" + filename
+			getArtifactPath = func(v Version) (string, error) {
+				return artifact, nil
+			}
 
-				getDownloadUrl = func(v Version) (string, error) {
-					return mongoUrl, nil
-				}
+			Convey("And no mirror is set", func() {
 				Convey("And XDG_CACHE_HOME env var is set", func() {
 					getEnv = func(key string) string {
 						if key == "XDG_CACHE_HOME" {
@@ -138,9 +141,60 @@ func TestNewConfig(t *testing.T) {
 						cfg, err := NewConfig(testCtx, version)
 						So(err, ShouldBeNil)
 						So(cfg.mongoVersion.String(), ShouldEqual, version)
-						So(cfg.mongoUrl, ShouldEqual, mongoUrl)
+						So(cfg.artifact, ShouldEqual, artifact)
+						So(cfg.source.Location(cfg.artifact), ShouldEqual, "https://fastdl.mongodb.org/linux/"+filename)
 						So(cfg.cachePath, ShouldEqual, "/cache/home/dp-mongodb-in-memory/"+filename+"/mongod")
 					})
+					Convey("Then the tools given by WithTools are cached in the same bundle as mongod", func() {
+						cfg, err := NewConfig(testCtx, version, WithTools("mongo", "mongos"))
+						So(err, ShouldBeNil)
+						So(cfg.binaryNames(), ShouldResemble, []string{"mongod", "mongos", "mongo"})
+						So(cfg.ToolPath("mongo"), ShouldEqual, "/cache/home/dp-mongodb-in-memory/"+filename+"/mongo")
+						So(cfg.MongosPath(), ShouldEqual, cfg.ToolPath("mongos"))
+					})
+					Convey("Then mongosh is cached in the same bundle, from the archive of the mongosh version", func() {
+						cfg, err := NewConfig(testCtx, version, WithTools("mongosh"), WithPlatform("linux", "arm64", "ubuntu2004"))
+						So(err, ShouldBeNil)
+						So(cfg.mongoshArtifactPath, ShouldEqual, "compass/mongosh-"+DefaultMongoshVersion+"-linux-arm64.tgz")
+						So(cfg.ToolPath("mongosh"), ShouldEqual, path.Join(path.Dir(cfg.MongoPath()), "mongosh"))
+
+						cfg, err = NewConfig(testCtx, version, WithTools("mongosh"), WithMongoshVersion("2.1.0"), WithPlatform("osx", "x86_64", ""))
+						So(err, ShouldBeNil)
+						So(cfg.mongoshArtifactPath, ShouldEqual, "compass/mongosh-2.1.0-darwin-x64.zip")
+					})
+					Convey("Then the tools not shipped with the MongoDB server are rejected", func() {
+						for name, msg := range map[string]string{
+							"mongodump": "it is one of the MongoDB Database Tools, installed with NewToolsConfig and GetDatabaseTools",
+							"mongotop2": "it is not shipped with the MongoDB server",
+						} {
+							cfg, err := NewConfig(testCtx, version, WithTools("mongo", name))
+							So(cfg, ShouldBeNil)
+							So(err, ShouldResemble, &UnsupportedToolError{Name: name, msg: msg})
+						}
+					})
+					Convey("Then the legacy mongo shell is rejected from MongoDB 6.0", func() {
+						cfg, err := NewConfig(testCtx, "6.0.1", WithTools("mongo"))
+						So(cfg, ShouldBeNil)
+						So(err, ShouldResemble, &UnsupportedToolError{Name: "mongo", msg: "it is not shipped with MongoDB 6.0.0 and above"})
+					})
+				})
+				Convey("And MIM_OFFLINE env var is set", func() {
+					getEnv = func(key string) string {
+						if key == OfflineEnv {
+							return "true"
+						}
+						return "/cache/home"
+					}
+					Convey("Then NewConfig enables the offline mode", func() {
+						cfg, err := NewConfig(testCtx, version)
+						So(err, ShouldBeNil)
+						So(cfg.offline, ShouldBeTrue)
+					})
+					Convey("Then the WithOffline option takes precedence", func() {
+						cfg, err := NewConfig(testCtx, version, WithOffline(false))
+						So(err, ShouldBeNil)
+						So(cfg.offline, ShouldBeFalse)
+					})
 				})
 				Convey("And XDG_CACHE_HOME env var is not set", func() {
 					userHome := "/usr/home"
@@ -156,7 +210,8 @@ func TestNewConfig(t *testing.T) {
 							cfg, err := NewConfig(testCtx, version)
 							So(err, ShouldBeNil)
 							So(cfg.mongoVersion.String(), ShouldEqual, version)
-							So(cfg.mongoUrl, ShouldEqual, mongoUrl)
+							So(cfg.artifact, ShouldEqual, artifact)
+							So(cfg.source.Location(cfg.artifact), ShouldEqual, "https://fastdl.mongodb.org/linux/"+filename)
 							So(cfg.cachePath, ShouldEqual, userHome+"/Library/Caches/dp-mongodb-in-memory/"+filename+"/mongod")
 						})
 					})
@@ -166,7 +221,8 @@ func TestNewConfig(t *testing.T) {
 							cfg, err := NewConfig(testCtx, version)
 							So(err, ShouldBeNil)
 							So(cfg.mongoVersion.String(), ShouldEqual, version)
-							So(cfg.mongoUrl, ShouldEqual, mongoUrl)
+							So(cfg.artifact, ShouldEqual, artifact)
+							So(cfg.source.Location(cfg.artifact), ShouldEqual, "https://fastdl.mongodb.org/linux/"+filename)
 							So(cfg.cachePath, ShouldEqual, userHome+"/.cache/dp-mongodb-in-memory/"+filename+"/mongod")
 						})
 					})
@@ -187,9 +243,85 @@ func TestNewConfig(t *testing.T) {
 				})
 			})
 
-			Convey("And the url is invalid", func() {
-				getDownloadUrl = func(v Version) (string, error) {
-					return ":invalid", nil
+			Convey("And a file:// mirror is set in MIM_DOWNLOAD_MIRROR", func() {
+				getEnv = func(key string) string {
+					if key == MirrorEnv {
+						return "file:///srv/mirror"
+					}
+					return "/cache/home"
+				}
+				Convey("Then NewConfig uses the mirror for the artifacts and the public keys", func() {
+					cfg, err := NewConfig(testCtx, version)
+					So(err, ShouldBeNil)
+					So(cfg.source.Location(cfg.artifact), ShouldEqual, "/srv/mirror/linux/"+filename)
+					So(cfg.keySource.Location("static/pgp/server-5.0.asc"), ShouldEqual, "/srv/mirror/static/pgp/server-5.0.asc")
+				})
+				Convey("Then the client given in WithHTTPClient is not used for a local mirror", func() {
+					cfg, err := NewConfig(testCtx, version, WithHTTPClient(&http.Client{}))
+					So(err, ShouldBeNil)
+					So(cfg.source, ShouldHaveSameTypeAs, &dirSource{})
+				})
+				Convey("Then the WithKeySource option overrides the source of the public keys only", func() {
+					cfg, err := NewConfig(testCtx, version, WithKeySource(NewHTTPSource("https://keys.example.com/", nil)))
+					So(err, ShouldBeNil)
+					So(cfg.source.Location(cfg.artifact), ShouldEqual, "/srv/mirror/linux/"+filename)
+					So(cfg.keySource.Location("static/pgp/server-5.0.asc"), ShouldEqual, "https://keys.example.com/static/pgp/server-5.0.asc")
+				})
+			})
+
+			Convey("And an HTTP client is given", func() {
+				client := &http.Client{}
+				getEnv = func(key string) string {
+					if key == "XDG_CACHE_HOME" {
+						return "/cache/home"
+					}
+					return ""
+				}
+				Convey("Then NewConfig uses it to download the artifacts and the public keys", func() {
+					cfg, err := NewConfig(testCtx, version, WithHTTPClient(client))
+					So(err, ShouldBeNil)
+					So(cfg.source.(*httpSource).client, ShouldEqual, client)
+					So(cfg.keySource.(*httpSource).client, ShouldEqual, client)
+				})
+			})
+
+			Convey("And stderr is a terminal", func() {
+				stderrIsTerminal = func() bool { return true }
+				getEnv = func(key string) string {
+					return ""
+				}
+				Convey("Then NewConfig renders the progress by default", func() {
+					cfg, err := NewConfig(testCtx, version)
+					So(err, ShouldBeNil)
+					So(cfg.progress, ShouldNotBeNil)
+				})
+				Convey("Then the channel given in WithProgressChan receives the progress instead", func() {
+					ch := make(chan Progress, 1)
+					cfg, err := NewConfig(testCtx, version, WithProgressChan(ch))
+					So(err, ShouldBeNil)
+					cfg.progress(Progress{Phase: PhaseDownloading, Bytes: 1, Total: 2})
+					So(<-ch, ShouldResemble, Progress{Phase: PhaseDownloading, Bytes: 1, Total: 2})
+				})
+				Convey("Then the progress is dropped instead of blocking when the channel is full", func() {
+					ch := make(chan Progress, 1)
+					cfg, err := NewConfig(testCtx, version, WithProgressChan(ch))
+					So(err, ShouldBeNil)
+					cfg.progress(Progress{Phase: PhaseDownloading, Bytes: 1, Total: 2})
+					cfg.progress(Progress{Phase: PhaseDownloading, Bytes: 2, Total: 2})
+					So(<-ch, ShouldResemble, Progress{Phase: PhaseDownloading, Bytes: 1, Total: 2})
+					So(ch, ShouldBeEmpty)
+				})
+				Reset(func() {
+					stderrIsTerminal = originalStderrIsTerminal
+				})
+			})
+
+			Convey("And an invalid mirror is set in MIM_DOWNLOAD_MIRROR", func() {
+				getEnv = func(key string) string {
+					if key == MirrorEnv {
+						return "ftp://mirror.example.com"
+					}
+					return "/cache/home"
 				}
 				Convey("Then NewConfig errors", func() {
 					cfg, err := NewConfig(testCtx, version)
@@ -199,15 +331,15 @@ func TestNewConfig(t *testing.T) {
 			})
 
 			Reset(func() {
-				getDownloadUrl = originalGetDownloadUrl
+				getArtifactPath = originalGetArtifactPath
 				getEnv = originalGetEnv
 			})
 
 		})
 
-		Convey("When an error occurs while determining the download url", func() {
+		Convey("When an error occurs while determining the artifact path", func() {
 			expectedError := errors.New("unsupported system")
-			getDownloadUrl = func(v Version) (string, error) {
+			getArtifactPath = func(v Version) (string, error) {
 				return "", expectedError
 			}
 
@@ -218,7 +350,7 @@ func TestNewConfig(t *testing.T) {
 			})
 
 			Reset(func() {
-				getDownloadUrl = originalGetDownloadUrl
+				getArtifactPath = originalGetArtifactPath
 			})
 		})
 	})
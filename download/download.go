@@ -2,14 +2,18 @@ package download
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"net"
 	"path"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,33 +24,152 @@ import (
 
 var afs = afero.Afero{Fs: afero.NewOsFs()}
 
-// GetMongoDB ensures there is a mongodb binary in the cache path
-// It will download one if not already present in the cache
+// binaries lists the executables always extracted from the MongoDB tarball into the cache
+var binaries = []string{"mongod", "mongos"}
+
+// serverTools lists the other executables of the MongoDB tarball that can be extracted with WithTools,
+// along with the first version not shipping them anymore
+var serverTools = map[string]Version{
+	"mongo": {Major: 6},
+}
+
+// GetMongoDB ensures there is a mongod binary, and the tools given by WithTools, in the cache path
+// It will download them, along with mongos, if not already present in the cache.
+// Concurrent calls, from this or other processes, are serialised through a lock on the cache entry,
+// so the binaries are downloaded only once; the other callers wait until the context is done
+// If the verification of the cache is enabled, binaries not matching the manifest written when they
+// were installed are downloaded again
 func GetMongoDB(ctx context.Context, cfg Config) error {
 	// Check the cache
-	existsInCache, existsErr := afs.Exists(cfg.cachePath)
+	existsInCache, existsErr := inCache(ctx, cfg)
 	if existsErr != nil {
 		log.Error(ctx, "error checking cache", existsErr)
 		return existsErr
 	}
 	if existsInCache {
 		log.Info(ctx, "File found in cache", log.Data{"filename": cfg.cachePath})
+		if err := touchCacheEntry(cfg.cachePath); err != nil {
+			log.Error(ctx, "error recording use of cache entry", err, log.Data{"filename": cfg.cachePath})
+		}
 		return nil
-	} else {
-		return downloadMongoDB(ctx, cfg)
+	} else if cfg.offline {
+		available, listErr := cachedVersions(path.Dir(cfg.cachePath), cfg.requiredBinaries())
+		if listErr != nil {
+			log.Error(ctx, "error listing cached versions", listErr)
+		}
+		return &NotCachedError{Product: "MongoDB", Version: cfg.mongoVersion.String(), Available: available}
+	}
+
+	// Only one process downloads a given version at a time: the others wait for it
+	// and use the binaries it stored in the cache
+	unlockEntry, lockErr := lockCacheEntry(ctx, cfg.cachePath)
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlockEntry()
+
+	existsInCache, existsErr = inCache(ctx, cfg)
+	if existsErr != nil {
+		log.Error(ctx, "error checking cache", existsErr)
+		return existsErr
+	}
+	if existsInCache {
+		log.Info(ctx, "File downloaded by another process found in cache", log.Data{"filename": cfg.cachePath})
+		return nil
+	}
+
+	return downloadMongoDB(ctx, cfg)
+}
+
+// Prefetch downloads the binaries of the given version into the cache without running them, with 0 or more options
+// as defined for NewConfig, e.g. WithPlatform and WithCacheRoot to warm a cache for another target.
+// It returns the config of the binaries, giving their paths
+func Prefetch(ctx context.Context, version string, opts ...ConfigOption) (*Config, error) {
+	cfg, err := NewConfig(ctx, version, opts...)
+	if err != nil {
+		return nil, err
 	}
+
+	if err = GetMongoDB(ctx, *cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// inCache checks whether the required binaries are in the cache and, if the verification of the cache is enabled,
+// whether they match their manifest
+func inCache(ctx context.Context, cfg Config) (bool, error) {
+	exists, err := hasBinaries(path.Dir(cfg.cachePath), cfg.requiredBinaries())
+	if err != nil || !exists || !cfg.verifyCache {
+		return exists, err
+	}
+
+	if err = verifyEntry(path.Dir(cfg.cachePath)); err != nil {
+		log.Warn(ctx, "Cached MongoDB does not match its manifest", log.Data{"filename": cfg.cachePath, "error": err.Error()})
+		return false, nil
+	}
+	return true, nil
+}
+
+// cachedVersions returns the sorted list of versions cached for the platform of the given cache entry directory
+// with the named binaries, i.e. the versions inCache would find
+func cachedVersions(entryDir string, names []string) ([]string, error) {
+	entries, err := NewCacheAt(path.Dir(entryDir)).List()
+	if err != nil {
+		return nil, err
+	}
+
+	var platform string
+	if match := cacheEntryRegexp.FindStringSubmatch(path.Base(entryDir)); match != nil {
+		platform = match[1]
+	}
+
+	seen := make(map[string]bool)
+	var versions []string
+	for _, entry := range entries {
+		if seen[entry.Version] || entry.Platform != platform {
+			continue
+		}
+		if exists, _ := hasBinaries(entry.Path, names); exists {
+			seen[entry.Version] = true
+			versions = append(versions, entry.Version)
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// hasBinaries checks whether all the named binaries are in the given cache entry directory
+func hasBinaries(dir string, names []string) (bool, error) {
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, path.Join(dir, name))
+	}
+	return allExist(paths)
+}
+
+// allExist checks whether all the given files exist
+func allExist(filenames []string) (bool, error) {
+	for _, filename := range filenames {
+		exists, err := afs.Exists(filename)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
 }
 
 // downloadMongoDB will download a mongodb tarball and
-// store the mongod exec file in the cache path.
-// It returns the path to the saved file
+// store the mongod and mongos exec files, and the requested tools (including mongosh), in the cache path.
 func downloadMongoDB(ctx context.Context, cfg Config) error {
+	// mongosh is not in the tarball, but in an archive of its own
+	names := slices.DeleteFunc(cfg.binaryNames(), func(name string) bool { return name == "mongosh" })
 
 	downloadStartTime := time.Now()
 
-	downloadedFile, downloadErr := downloadFile(ctx, cfg.mongoUrl)
+	downloadedFile, downloadErr := downloadFile(ctx, cfg.source, cfg.artifact, cfg.progress)
 	if downloadErr != nil {
-		log.Error(ctx, "error downloading file", downloadErr, log.Data{"url": cfg.mongoUrl})
+		log.Error(ctx, "error downloading file", downloadErr, log.Data{"url": cfg.source.Location(cfg.artifact)})
 		return downloadErr
 	}
 
@@ -55,27 +178,38 @@ func downloadMongoDB(ctx context.Context, cfg Config) error {
 		_ = afs.Remove(downloadedFile.Name())
 	}()
 
-	validErr := verify(ctx, cfg, downloadedFile.Name())
+	tarballChecksum, keyFingerprint, validErr := verify(ctx, cfg, downloadedFile.Name())
 	if validErr != nil {
-		log.Error(ctx, "error verifying integrity of MongoDB package", validErr, log.Data{"url": cfg.mongoUrl})
+		log.Error(ctx, "error verifying integrity of MongoDB package", validErr, log.Data{"url": cfg.source.Location(cfg.artifact)})
 		return validErr
 	}
 
-	mongodTmpFile, mongoTmpErr := extractMongoBin(ctx, downloadedFile)
-	if mongoTmpErr != nil {
-		return mongoTmpErr
+	tarballSize := contentSize(downloadedFile)
+	tmpFiles, extractErr := extractMongoBins(ctx, downloadedFile, names, newProgressWriter(cfg.progress, PhaseExtracting, tarballSize))
+	if extractErr != nil {
+		return &ExtractionError{URL: cfg.source.Location(cfg.artifact), Err: extractErr}
+	}
+	if cfg.progress != nil {
+		// The extraction stops as soon as the binaries are found, before the end of the tarball
+		cfg.progress(Progress{Phase: PhaseExtracting, Bytes: tarballSize, Total: tarballSize})
 	}
 
-	mkdirErr := afs.MkdirAll(path.Dir(cfg.cachePath), 0755)
-	if mkdirErr != nil {
-		log.Error(ctx, "error creating cache directory", mkdirErr, log.Data{"dir": path.Dir(cfg.cachePath)})
-		return mkdirErr
+	if cfg.mongoshArtifactPath != "" {
+		mongoshFiles, mongoshErr := downloadMongosh(ctx, cfg)
+		if mongoshErr != nil {
+			removeAll(tmpFiles)
+			return mongoshErr
+		}
+		tmpFiles["mongosh"] = mongoshFiles["mongosh"]
 	}
 
-	renameErr := afs.Rename(mongodTmpFile, cfg.cachePath)
-	if renameErr != nil {
-		log.Error(ctx, "error copying mongod binary", renameErr, log.Data{"filename-from": mongodTmpFile, "filename-to": cfg.cachePath})
-		return renameErr
+	manifest := &Manifest{
+		Source:         cfg.source.Location(cfg.artifact),
+		TarballSHA256:  tarballChecksum,
+		KeyFingerprint: keyFingerprint,
+	}
+	if installErr := installBinaries(ctx, path.Dir(cfg.cachePath), tmpFiles, manifest); installErr != nil {
+		return installErr
 	}
 
 	log.Info(ctx, "mongod downloaded and stored in cache", log.Data{"filename": cfg.cachePath, "ellapsed": time.Since(downloadStartTime).String()})
@@ -83,37 +217,97 @@ func downloadMongoDB(ctx context.Context, cfg Config) error {
 	return nil
 }
 
-// downloadFile downloads the file from the given url and stores it in a temporary file.
-// It returns the temporary file where it has been downloaded
-func downloadFile(ctx context.Context, urlStr string) (afero.File, error) {
-	log.Info(ctx, "Downloading file", log.Data{"url": urlStr})
+// installBinaries moves the extracted binaries, given by name, into the cache entry directory
+// and writes its manifest, recording their checksums along with where they come from
+func installBinaries(ctx context.Context, cacheDir string, tmpFiles map[string]string, manifest *Manifest) error {
+	mkdirErr := afs.MkdirAll(cacheDir, 0755)
+	if mkdirErr != nil {
+		log.Error(ctx, "error creating cache directory", mkdirErr, log.Data{"dir": cacheDir})
+		removeAll(tmpFiles)
+		return mkdirErr
+	}
+
+	manifest.Binaries = make(map[string]string, len(tmpFiles))
+	for name, tmpFile := range tmpFiles {
+		checksum, sumErr := sha256Sum(tmpFile, io.Discard)
+		if sumErr != nil {
+			log.Error(ctx, "error calculating SHA256 sum of "+name+" binary", sumErr, log.Data{"filename": tmpFile})
+			removeAll(tmpFiles)
+			return sumErr
+		}
+		manifest.Binaries[name] = checksum
+	}
 
-	resp, httpGetErr := http.Get(urlStr)
-	if httpGetErr != nil {
-		return nil, httpGetErr
+	// The binaries being replaced are not described by the previous manifest anymore
+	_ = afs.Remove(path.Join(cacheDir, manifestFileName))
+	for name, tmpFile := range tmpFiles {
+		binPath := path.Join(cacheDir, name)
+		renameErr := afs.Rename(tmpFile, binPath)
+		if renameErr != nil {
+			log.Error(ctx, "error copying "+name+" binary", renameErr, log.Data{"filename-from": tmpFile, "filename-to": binPath})
+			return renameErr
+		}
 	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("invalid status code %d", resp.StatusCode)
+
+	manifest.InstalledAt = time.Now().UTC()
+	if writeErr := writeManifest(cacheDir, manifest); writeErr != nil {
+		log.Error(ctx, "error writing manifest of the binaries", writeErr, log.Data{"dir": cacheDir})
+		return writeErr
 	}
+	return nil
+}
+
+// Retry policy of the downloads.
+// We define them as package vars so we can override them in tests
+var (
+	maxDownloadAttempts = 5
+	retryBaseDelay      = 500 * time.Millisecond
+	retryMaxDelay       = 10 * time.Second
+)
 
-	defer resp.Body.Close()
+// downloadFile downloads the named artifact from the given source and stores it in a temporary file.
+// Transient failures are retried with exponential backoff, resuming from where the transfer stopped
+// if the source supports it. The progress of the transfer is reported to the given function, if not nil.
+// It returns the temporary file where it has been downloaded
+func downloadFile(ctx context.Context, src Source, name string, progress ProgressFunc) (afero.File, error) {
+	urlStr := src.Location(name)
+	log.Info(ctx, "Downloading file", log.Data{"url": urlStr})
 
 	tgzTempFile, tmpFileErr := afs.TempFile("", "")
 	if tmpFileErr != nil {
 		return nil, tmpFileErr
 	}
 
-	_, copyErr := io.Copy(tgzTempFile, resp.Body)
-	if copyErr != nil {
-		_ = tgzTempFile.Close()
-		_ = afs.Remove(tgzTempFile.Name())
-		return nil, copyErr
+	var written int64
+	for attempt := 1; ; attempt++ {
+		var fetchErr error
+		written, fetchErr = fetchInto(ctx, src, name, tgzTempFile, written, progress)
+		if fetchErr == nil {
+			break
+		}
+
+		if attempt >= maxDownloadAttempts || !isTemporary(ctx, fetchErr) {
+			_ = tgzTempFile.Close()
+			_ = afs.Remove(tgzTempFile.Name())
+			return nil, fetchErr
+		}
+
+		delay := retryDelay(attempt)
+		log.Warn(ctx, "Download failed, retrying", log.Data{"url": urlStr, "attempt": attempt, "bytes": written, "delay": delay.String(), "error": fetchErr.Error()})
+		select {
+		case <-ctx.Done():
+			_ = tgzTempFile.Close()
+			_ = afs.Remove(tgzTempFile.Name())
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
 	}
+	_ = tgzTempFile.Close()
 
-	tgzTempFile, err := afs.Open(tgzTempFile.Name())
+	tmpName := tgzTempFile.Name()
+	tgzTempFile, err := afs.Open(tmpName)
 	if err != nil {
-		_ = tgzTempFile.Close()
-		_ = afs.Remove(tgzTempFile.Name())
+		_ = afs.Remove(tmpName)
 		return nil, err
 	}
 
@@ -121,89 +315,227 @@ func downloadFile(ctx context.Context, urlStr string) (afero.File, error) {
 	return tgzTempFile, nil
 }
 
-// extractMongoBin extracts the mongod executable file
-// from the given tarball to a temporary file.
-// It returns the path to the extracted file
-func extractMongoBin(ctx context.Context, tgzTempFile afero.File) (string, error) {
+// fetchInto writes the named artifact into the given file, which already holds the first `written` bytes.
+// If the source can resume the transfer, only the missing content is fetched; otherwise the file is overwritten.
+// It returns the number of bytes held by the file
+func fetchInto(ctx context.Context, src Source, name string, file afero.File, written int64, progress ProgressFunc) (int64, error) {
+	var body io.ReadCloser
+	var offset int64
+	var err error
+	if rangeSrc, ok := src.(RangeSource); ok && written > 0 {
+		body, offset, err = rangeSrc.OpenRange(ctx, name, written)
+	} else {
+		body, err = src.Open(ctx, name)
+	}
+	if err != nil {
+		return written, err
+	}
+	defer body.Close()
+
+	if offset != written {
+		if err = file.Truncate(offset); err != nil {
+			return 0, err
+		}
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	total := int64(-1)
+	if size := contentSize(body); size >= 0 {
+		total = offset + size
+	}
+	counter := newProgressWriter(progress, PhaseDownloading, total)
+	if pw, ok := counter.(*progressWriter); ok {
+		pw.bytes = offset
+	}
+
+	n, err := io.Copy(io.MultiWriter(file, counter), body)
+	return offset + n, err
+}
+
+// isTemporary reports whether a download error is worth retrying:
+// server errors, network errors and transfers cut short, unless the context is done
+func isTemporary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryDelay returns the time to wait before the next download attempt, doubling after every attempt
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > retryMaxDelay {
+		return retryMaxDelay
+	}
+	return delay
+}
+
+// extractMongoBins extracts the named executable files (e.g. mongod)
+// from the given tarball to temporary files.
+// The bytes of the tarball read are written to the progress writer.
+// It returns the path to the extracted files, keyed by name
+func extractMongoBins(ctx context.Context, tgzTempFile afero.File, names []string, progress io.Writer) (map[string]string, error) {
 	_, seekErr := tgzTempFile.Seek(0, 0)
 	if seekErr != nil {
 		log.Error(ctx, "error seeking back to start of file", seekErr)
-		return "", seekErr
+		return nil, seekErr
 	}
 
-	gzReader, gzErr := gzip.NewReader(tgzTempFile)
+	gzReader, gzErr := gzip.NewReader(io.TeeReader(tgzTempFile, progress))
 	if gzErr != nil {
 		log.Error(ctx, "error intializing gzip reader", gzErr, log.Data{"file": tgzTempFile.Name()})
-		return "", gzErr
+		return nil, gzErr
 	}
 
 	tarReader := tar.NewReader(gzReader)
+	extracted := make(map[string]string, len(names))
 
-	for {
+	for len(extracted) < len(names) {
 		nextFile, tarErr := tarReader.Next()
 		if tarErr == io.EOF {
-			return "", fmt.Errorf("did not find a mongod binary in the tar file")
+			removeAll(extracted)
+			for _, name := range names {
+				if _, ok := extracted[name]; !ok {
+					return nil, fmt.Errorf("did not find a %s binary in the tar file", name)
+				}
+			}
 		}
 		if tarErr != nil {
 			log.Error(ctx, "error reading from tar file", tarErr, log.Data{"file": tgzTempFile.Name()})
-			return "", tarErr
+			removeAll(extracted)
+			return nil, tarErr
 		}
 
-		if strings.HasSuffix(nextFile.Name, "bin/mongod") {
-			break
+		for _, name := range names {
+			if strings.HasSuffix(nextFile.Name, "bin/"+name) {
+				tmpFile, extractErr := extractFile(ctx, tarReader, name)
+				if extractErr != nil {
+					removeAll(extracted)
+					return nil, extractErr
+				}
+				extracted[name] = tmpFile
+			}
 		}
 	}
 
+	return extracted, nil
+}
+
+// extractZipBins extracts the named executable files (e.g. mongodump)
+// from the given zip archive to temporary files.
+// It returns the path to the extracted files, keyed by name
+func extractZipBins(ctx context.Context, zipTempFile afero.File, names []string) (map[string]string, error) {
+	zipReader, zipErr := zip.NewReader(zipTempFile, contentSize(zipTempFile))
+	if zipErr != nil {
+		log.Error(ctx, "error intializing zip reader", zipErr, log.Data{"file": zipTempFile.Name()})
+		return nil, zipErr
+	}
+
+	extracted := make(map[string]string, len(names))
+	for _, zipEntry := range zipReader.File {
+		for _, name := range names {
+			if !strings.HasSuffix(zipEntry.Name, "bin/"+name) {
+				continue
+			}
+			entryReader, openErr := zipEntry.Open()
+			if openErr != nil {
+				log.Error(ctx, "error reading from zip file", openErr, log.Data{"file": zipTempFile.Name()})
+				removeAll(extracted)
+				return nil, openErr
+			}
+			tmpFile, extractErr := extractFile(ctx, entryReader, name)
+			_ = entryReader.Close()
+			if extractErr != nil {
+				removeAll(extracted)
+				return nil, extractErr
+			}
+			extracted[name] = tmpFile
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := extracted[name]; !ok {
+			removeAll(extracted)
+			return nil, fmt.Errorf("did not find a %s binary in the zip file", name)
+		}
+	}
+	return extracted, nil
+}
+
+// extractFile copies the current tar entry into an executable temporary file.
+// It returns the path to the extracted file
+func extractFile(ctx context.Context, tarReader io.Reader, name string) (string, error) {
 	// Extract to a temp file first, then copy to the destination, so we get
 	// atomic behavior if there's multiple parallel downloaders
-	mongodTmpFile, tmpFileErr := afs.TempFile("", "")
+	tmpFile, tmpFileErr := afs.TempFile("", "")
 	if tmpFileErr != nil {
-		log.Error(ctx, "error creating temp file for mongod", tmpFileErr)
+		log.Error(ctx, "error creating temp file for "+name, tmpFileErr)
 		return "", tmpFileErr
 	}
 	defer func() {
-		_ = mongodTmpFile.Close()
+		_ = tmpFile.Close()
 	}()
 
-	_, writeErr := io.Copy(mongodTmpFile, tarReader)
+	_, writeErr := io.Copy(tmpFile, tarReader)
 	if writeErr != nil {
-		log.Error(ctx, "error writing mongod binary", writeErr, log.Data{"filename": mongodTmpFile.Name()})
+		log.Error(ctx, "error writing "+name+" binary", writeErr, log.Data{"filename": tmpFile.Name()})
+		_ = afs.Remove(tmpFile.Name())
 		return "", writeErr
 	}
 
-	_ = mongodTmpFile.Close()
+	_ = tmpFile.Close()
 
-	chmodErr := afs.Chmod(mongodTmpFile.Name(), 0755)
+	chmodErr := afs.Chmod(tmpFile.Name(), 0755)
 	if chmodErr != nil {
-		log.Error(ctx, "error chmod-ing mongod binary", chmodErr, log.Data{"filename": mongodTmpFile.Name()})
+		log.Error(ctx, "error chmod-ing "+name+" binary", chmodErr, log.Data{"filename": tmpFile.Name()})
+		_ = afs.Remove(tmpFile.Name())
 		return "", chmodErr
 	}
-	return mongodTmpFile.Name(), nil
+	return tmpFile.Name(), nil
+}
+
+// removeAll removes the given temporary files
+func removeAll(files map[string]string) {
+	for _, f := range files {
+		_ = afs.Remove(f)
+	}
 }
 
 // verify checks the integrity of the mongoFile.
-// It uses the config file to download the checksum and signature files
-// and compares their value against the actual mongoFile checksum and GPG signature
-func verify(ctx context.Context, cfg Config, mongoFile string) error {
-	if err := verifyChecksum(ctx, cfg, mongoFile); err != nil {
-		return err
+// It uses the config file to download the checksum and signature files from the source
+// and compares their value against the actual mongoFile checksum and GPG signature.
+// It returns the checksum of the mongoFile and the fingerprint of the key that signed it
+func verify(ctx context.Context, cfg Config, mongoFile string) (string, string, error) {
+	checksum, err := verifyChecksum(ctx, cfg, mongoFile)
+	if err != nil {
+		return "", "", err
 	}
-	log.Info(ctx, "checksum verified successfully", log.Data{"url": cfg.mongoChecksumUrl()})
+	log.Info(ctx, "checksum verified successfully", log.Data{"url": cfg.source.Location(cfg.mongoChecksumArtifact())})
 
-	if err := verifySignature(ctx, cfg, mongoFile); err != nil {
-		return err
+	fingerprint, err := verifySignature(ctx, cfg, mongoFile)
+	if err != nil {
+		return "", "", err
 	}
-	log.Info(ctx, "signature verified successfully", log.Data{"url": cfg.mongoSignatureUrl()})
+	log.Info(ctx, "signature verified successfully", log.Data{"url": cfg.source.Location(cfg.mongoSignatureArtifact()), "key": fingerprint})
 
-	return nil
+	return checksum, fingerprint, nil
 }
 
-func verifyChecksum(ctx context.Context, cfg Config, mongoFile string) error {
+// verifyChecksum checks the SHA256 checksum of the mongoFile against the published one, and returns it
+func verifyChecksum(ctx context.Context, cfg Config, mongoFile string) (string, error) {
 
-	checksumFile, downloadErr := downloadFile(ctx, cfg.mongoChecksumUrl())
+	checksumFile, downloadErr := downloadFile(ctx, cfg.source, cfg.mongoChecksumArtifact(), nil)
 	if downloadErr != nil {
-		log.Error(ctx, "error downloading checksum file", downloadErr, log.Data{"url": cfg.mongoChecksumUrl()})
-		return downloadErr
+		log.Error(ctx, "error downloading checksum file", downloadErr, log.Data{"url": cfg.source.Location(cfg.mongoChecksumArtifact())})
+		return "", downloadErr
 	}
 
 	defer func() {
@@ -214,46 +546,49 @@ func verifyChecksum(ctx context.Context, cfg Config, mongoFile string) error {
 	content, err := afs.ReadFile(checksumFile.Name())
 	if err != nil {
 		log.Error(ctx, "error reading checksum file", err)
-		return err
+		return "", err
 	}
 	s := strings.Split(string(content), " ")
 	checksum := s[0]
 
-	mongoChecksum, err := sha256Sum(mongoFile)
+	mongoChecksum, err := sha256Sum(mongoFile, newProgressWriter(cfg.progress, PhaseVerifyingChecksum, fileSize(mongoFile)))
 	if err != nil {
 		log.Error(ctx, "error calculating SHA256 sum", err)
-		return err
+		return "", err
 	}
 
 	if checksum != mongoChecksum {
-		return fmt.Errorf("checksum verification failed")
+		return "", &ChecksumMismatchError{Name: cfg.source.Location(cfg.artifact), Expected: checksum, Actual: mongoChecksum}
 	}
-	return nil
+	return mongoChecksum, nil
 }
 
-func verifySignature(ctx context.Context, cfg Config, mongoFilename string) error {
+// verifySignature checks the GPG signature of the mongoFile against the MongoDB public key,
+// and returns the fingerprint of the key that signed it
+func verifySignature(ctx context.Context, cfg Config, mongoFilename string) (string, error) {
 	// Get public key
-	keyFile, err := getMongoPublicKey(ctx, cfg.mongoVersion)
+	key, err := cfg.signingKeyFor(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	defer func() {
-		_ = keyFile.Close()
-		_ = afs.Remove(keyFile.Name())
-	}()
+	return checkSignature(ctx, cfg, cfg.source, cfg.mongoSignatureArtifact(), key, mongoFilename)
+}
 
-	keyring, err := openpgp.ReadArmoredKeyRing(keyFile)
+// checkSignature checks the file against the named signature, downloaded from the given source,
+// and returns the fingerprint of the key that signed it, which must be the given one
+func checkSignature(ctx context.Context, cfg Config, src Source, signatureName string, key *signingKey, filename string) (string, error) {
+	keyring, err := key.keyring()
 	if err != nil {
 		log.Error(ctx, "error reading keyring file", err)
-		return err
+		return "", err
 	}
 
 	// Get signature
-	signatureFile, err := downloadFile(ctx, cfg.mongoSignatureUrl())
+	signatureFile, err := downloadFile(ctx, src, signatureName, nil)
 	if err != nil {
-		log.Error(ctx, "error downloading signature file", err, log.Data{"url": cfg.mongoSignatureUrl()})
-		return err
+		log.Error(ctx, "error downloading signature file", err, log.Data{"url": src.Location(signatureName)})
+		return "", err
 	}
 
 	defer func() {
@@ -262,46 +597,64 @@ func verifySignature(ctx context.Context, cfg Config, mongoFilename string) erro
 	}()
 
 	// Get file to verify
-	mongoFile, err := afs.Open(mongoFilename)
+	file, err := afs.Open(filename)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	defer func() {
-		_ = mongoFile.Close()
+		_ = file.Close()
 	}()
 
 	// Verify signature
-	_, err = openpgp.CheckArmoredDetachedSignature(keyring, mongoFile, signatureFile)
+	progress := newProgressWriter(cfg.progress, PhaseVerifyingSignature, contentSize(file))
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, io.TeeReader(file, progress), signatureFile)
 	if err != nil {
-		return fmt.Errorf("signature verification failed")
+		return "", &SignatureError{URL: src.Location(signatureName), Err: err}
 	}
 
-	return nil
+	fingerprint := fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	if err = key.checkSigner(ctx, fingerprint); err != nil {
+		return "", err
+	}
+	return fingerprint, nil
 }
 
-var getMongoPublicKey = func(ctx context.Context, version Version) (afero.File, error) {
-	keyUrl := fmt.Sprintf("https://www.mongodb.org/static/pgp/server-%d.%d.asc", version.Major, version.Minor)
+// getMongoPublicKey returns the public key named <name>.asc (e.g. server-7.0.asc), from the given source
+// We define it as a package var so we can override it in tests
+var getMongoPublicKey = func(ctx context.Context, src Source, name string) (io.ReadCloser, error) {
+	keyName := "static/pgp/" + name + ".asc"
 
-	keyFile, err := downloadFile(ctx, keyUrl)
+	keyFile, err := src.Open(ctx, keyName)
 	if err != nil {
-		log.Error(ctx, "error downloading Mongo public key", err, log.Data{"url": keyUrl})
+		log.Error(ctx, "error downloading Mongo public key", err, log.Data{"url": src.Location(keyName)})
 		return nil, err
 	}
 	return keyFile, nil
 }
 
-// sha256Sum returns the SHA256 checksum of the file
-func sha256Sum(filename string) (string, error) {
+// sha256Sum returns the SHA256 checksum of the file.
+// The bytes of the file read are written to the progress writer
+func sha256Sum(filename string, progress io.Writer) (string, error) {
 	file, err := afs.Open(filename)
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 
 	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	if _, err := io.Copy(io.MultiWriter(hash, progress), file); err != nil {
 		return "", err
 	}
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
+
+// fileSize returns the size of the file, or -1 if unknown
+func fileSize(filename string) int64 {
+	info, err := afs.Stat(filename)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
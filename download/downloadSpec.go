@@ -14,6 +14,10 @@ import (
 
 const etcOsReleaseFileName = "/etc/os-release"
 
+// LinuxDistroEnv is the environment variable giving the name of the MongoDB build to download on Linux
+// (e.g. ubuntu2204 or rhel80), instead of the one detected from /etc/os-release
+const LinuxDistroEnv = "MIM_LINUX_DISTRO"
+
 // We define these as package vars so we can override it in tests
 
 var goOS = runtime.GOOS
@@ -27,28 +31,18 @@ type DownloadSpec struct {
 	// Platform is "osx" or "linux"
 	Platform string
 
-	// Arch
+	// Arch is "x86_64" or "arm64". The Linux tarballs name ARM "aarch64" instead (see GetArtifactPath)
 	Arch string
 
-	// OSName is one of:
-	// - ubuntu2204
-	// - ubuntu2004
-	// - ubuntu1804
-	// - ubuntu1604
-	// - debian10
-	// - debian92
-	// - amazon2
-	// - "" for MacOS
+	// OSName is the name of the MongoDB build for the Linux distribution, e.g. ubuntu2204 or rhel80
+	// (see linuxDistros), or "" for MacOS
 	OSName string
 }
 
 // MakeDownloadSpec returns a DownloadSpec for the current operating system
 func MakeDownloadSpec(version Version) (*DownloadSpec, error) {
-	if !version.IsGreaterOrEqual(4, 4, 0) {
-		return nil, &UnsupportedMongoVersionError{
-			version: version.String(),
-			msg:     "only version 4.4 and above are supported",
-		}
+	if err := checkSupportedVersion(version); err != nil {
+		return nil, err
 	}
 
 	arch, archErr := detectArch()
@@ -61,7 +55,7 @@ func MakeDownloadSpec(version Version) (*DownloadSpec, error) {
 		return nil, platformErr
 	}
 
-	osName, osErr := detectLinuxId()
+	osName, osErr := detectLinuxId(version, arch)
 	if osErr != nil {
 		return nil, osErr
 	}
@@ -74,9 +68,81 @@ func MakeDownloadSpec(version Version) (*DownloadSpec, error) {
 	}, nil
 }
 
+// NewDownloadSpec returns a DownloadSpec for the given platform ("linux" or "osx"), architecture ("x86_64" or "arm64",
+// also given as "aarch64") and, on Linux, name of the MongoDB build for the distribution (e.g. "ubuntu2204" or "rhel80"), whatever the current system
+func NewDownloadSpec(version Version, platform, arch, osName string) (*DownloadSpec, error) {
+	if err := checkSupportedVersion(version); err != nil {
+		return nil, err
+	}
+
+	arch, osName, err := checkPlatform(platform, arch, osName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DownloadSpec{
+		version:  &version,
+		Arch:     arch,
+		Platform: platform,
+		OSName:   osName,
+	}, nil
+}
+
+// checkPlatform returns an error if the given platform ("linux" or "osx"), architecture ("x86_64", "arm64" or "aarch64")
+// and Linux build name are not supported. It returns the architecture, with aarch64 given as arm64,
+// and the build name, cleared for MacOS
+func checkPlatform(platform, arch, osName string) (string, string, error) {
+	switch platform {
+	case "linux":
+		if osName == "" {
+			return "", "", fmt.Errorf("invalid spec: OS name not provided")
+		}
+	case "osx":
+		osName = ""
+	default:
+		return "", "", &UnsupportedSystemError{msg: "platform " + platform + " not supported"}
+	}
+
+	switch arch {
+	case "x86_64", "arm64":
+	case "aarch64":
+		arch = "arm64"
+	default:
+		return "", "", &UnsupportedSystemError{msg: "architecture " + arch + " not supported"}
+	}
+
+	if platform == "linux" && !linuxBuildFor(osName, arch) {
+		return "", "", &UnsupportedSystemError{msg: "linux build " + osName + " not published for " + arch}
+	}
+	return arch, osName, nil
+}
+
+// checkSupportedVersion returns an error if binaries can not be downloaded for the given version
+func checkSupportedVersion(version Version) error {
+	if !version.IsGreaterOrEqual(4, 4, 0) {
+		return &UnsupportedMongoVersionError{
+			version: version.String(),
+			msg:     "only version 4.4 and above are supported",
+		}
+	}
+	return nil
+}
+
 // GetDownloadURL returns the download URL to download the binary
 // from the MongoDB website
 func (spec *DownloadSpec) GetDownloadURL() (string, error) {
+	artifactPath, err := spec.GetArtifactPath()
+	if err != nil {
+		return "", err
+	}
+
+	return DefaultDownloadURL + "/" + artifactPath, nil
+}
+
+// GetArtifactPath returns the path to the binary tarball, relative to the root of a download Source
+// The Linux tarballs for ARM are named after aarch64, e.g. mongodb-linux-aarch64-ubuntu2204-7.0.4.tgz,
+// while the MacOS ones are named after arm64
+func (spec *DownloadSpec) GetArtifactPath() (string, error) {
 	archiveName := "mongodb-"
 
 	switch spec.Platform {
@@ -84,7 +150,7 @@ func (spec *DownloadSpec) GetDownloadURL() (string, error) {
 		if spec.OSName == "" {
 			return "", fmt.Errorf("invalid spec: OS name not provided")
 		}
-		archiveName += "linux-" + spec.Arch + "-" + spec.OSName
+		archiveName += "linux-" + linuxArch(spec.Arch) + "-" + spec.OSName
 	case "osx":
 		archiveName += "macos-" + spec.Arch
 	default:
@@ -92,7 +158,7 @@ func (spec *DownloadSpec) GetDownloadURL() (string, error) {
 	}
 
 	return fmt.Sprintf(
-		"https://fastdl.mongodb.org/%s/%s-%s.tgz",
+		"%s/%s-%s.tgz",
 		spec.Platform,
 		archiveName,
 		spec.Version(),
@@ -104,6 +170,14 @@ func (spec *DownloadSpec) Version() string {
 	return spec.version.String()
 }
 
+// linuxArch returns the name of the architecture in the Linux tarballs
+func linuxArch(arch string) string {
+	if arch == "arm64" {
+		return "aarch64"
+	}
+	return arch
+}
+
 func detectPlatform() (string, error) {
 	switch goOS {
 	case "darwin":
@@ -126,12 +200,36 @@ func detectArch() (string, error) {
 	}
 }
 
-func detectLinuxId() (string, error) {
+// detectLinuxId returns the name of the MongoDB build of the given version for the current Linux distribution,
+// as found in /etc/os-release. If the distribution (ID) is not known, the ones it is like (ID_LIKE) are tried in order
+// The MIM_LINUX_DISTRO environment variable overrides the detection
+func detectLinuxId(mongoVersion Version, arch string) (string, error) {
+	return detectLinuxBuild(func(distro *linuxDistro, osVersion int) (string, error) {
+		return distro.build(osVersion, arch, mongoVersion)
+	})
+}
+
+// detectToolsLinuxId returns the name of the MongoDB Database Tools build for the current Linux distribution:
+// the newest MongoDB build for the distribution version, whatever the MongoDB release series
+// The MIM_LINUX_DISTRO environment variable overrides the detection
+func detectToolsLinuxId(arch string) (string, error) {
+	return detectLinuxBuild(func(distro *linuxDistro, osVersion int) (string, error) {
+		return distro.newestBuild(osVersion, arch)
+	})
+}
+
+// detectLinuxBuild finds the current Linux distribution and its major version in /etc/os-release,
+// and returns the name of the build chosen for them by the given function
+func detectLinuxBuild(choose func(distro *linuxDistro, osVersion int) (string, error)) (string, error) {
 	if goOS != "linux" {
 		// Not on Linux
 		return "", nil
 	}
 
+	if distro := getEnv(LinuxDistroEnv); distro != "" {
+		return distro, nil
+	}
+
 	osreleaseFile, err := afs.Open(etcOsReleaseFileName)
 	if err != nil {
 		log.Error(context.Background(), "error reading "+etcOsReleaseFileName+" file", err)
@@ -145,45 +243,33 @@ func detectLinuxId() (string, error) {
 	}
 
 	id := osRelease["ID"]
+	distroID := id
+	distro, ok := linuxDistros[id]
+	if !ok {
+		for _, like := range strings.Fields(osRelease["ID_LIKE"]) {
+			if distro, ok = linuxDistros[like]; ok {
+				distroID = like
+				break
+			}
+		}
+	}
+	if !ok {
+		return "", &UnsupportedSystemError{msg: "invalid linux version '" + id + "'"}
+	}
+
 	versionString := strings.Split(osRelease["VERSION_ID"], ".")[0]
+	if distroID != id {
+		// Derivatives (e.g. Linux Mint) have their own version numbers, but usually give the codename of their base
+		if v, ok := linuxCodenames[osRelease[distro.codenameKey]]; ok {
+			versionString = strconv.Itoa(v)
+		}
+	}
 	version, versionErr := strconv.Atoi(versionString)
 	if versionErr != nil {
 		return "", &UnsupportedSystemError{msg: "invalid version number " + versionString}
 	}
-	switch id {
-	case "ubuntu":
-		if version >= 22 {
-			return "ubuntu2204", nil
-		}
-		if version >= 20 {
-			return "ubuntu2004", nil
-		}
-		if version >= 18 {
-			return "ubuntu1804", nil
-		}
-		if version >= 16 {
-			return "ubuntu1604", nil
-		}
-		return "", &UnsupportedSystemError{msg: "invalid ubuntu version " + versionString + " (min 16)"}
-	case "debian":
-		if version >= 12 {
-			return "debian12", nil
-		}
-		if version >= 10 {
-			return "debian10", nil
-		}
-		if version >= 9 {
-			return "debian92", nil
-		}
-		return "", &UnsupportedSystemError{msg: "invalid debian version " + versionString + " (min 9)"}
-	case "amzn":
-		if version == 2 {
-			return "amazon2", nil
-		}
-		return "", &UnsupportedSystemError{msg: "invalid amazon linux version " + versionString + " (only 2)"}
-	default:
-		return "", &UnsupportedSystemError{msg: "invalid linux version '" + id + "'"}
-	}
+
+	return choose(&distro, version)
 }
 
 func readKeyValuePairs(r io.Reader) (map[string]string, error) {
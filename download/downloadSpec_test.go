@@ -40,6 +40,16 @@ func TestGetDownloadURL(t *testing.T) {
 					So(url, ShouldEqual, "https://fastdl.mongodb.org/linux/mongodb-linux-x86_64-ubuntu2004-5.0.2.tgz")
 				})
 			})
+			Convey("And the version is a release candidate", func() {
+				spec.OSName = "ubuntu2204"
+				spec.version = &Version{Major: 8, Minor: 0, Patch: 0, Prerelease: "rc3"}
+				Convey("Then GetDownloadURL builds the right url", func() {
+					url, err := spec.GetDownloadURL()
+
+					So(err, ShouldBeNil)
+					So(url, ShouldEqual, "https://fastdl.mongodb.org/linux/mongodb-linux-x86_64-ubuntu2204-8.0.0-rc3.tgz")
+				})
+			})
 			Convey("And no linux id is provided", func() {
 				spec.OSName = ""
 				Convey("Then an error is thrown", func() {
@@ -69,6 +79,7 @@ func TestGetDownloadURL(t *testing.T) {
 func TestMakeDownloadSpec(t *testing.T) {
 	var originalGoOs = goOS
 	var originalGoArch = goArch
+	var originalGetEnv = getEnv
 
 	// Use a memory backed filesystem (no persistence)
 	afs = afero.Afero{Fs: afero.NewMemMapFs()}
@@ -122,22 +133,64 @@ func TestMakeDownloadSpec(t *testing.T) {
 			Convey("And on Linux", func() {
 				goOS = "linux"
 
+				version7 := Version{Major: 7, Minor: 0, Patch: 4}
+				version8 := Version{Major: 8, Minor: 0, Patch: 1}
+
 				tests := map[string]struct {
 					linuxId      string
 					linuxVersion string
+					// osRelease holds more lines of the os-release file, if any
+					osRelease string
+					// mongoVersion is the version to get the spec for, if not the default one
+					mongoVersion *Version
+					// goArch is the architecture of the host, if not amd64
+					goArch       string
 					expectedSpec *DownloadSpec
 					expectedErr  error
 				}{
-					"Ubuntu 22.04": {
+					"Ubuntu 24.04 with MongoDB 8.0": {
+						linuxId:      "ubuntu",
+						linuxVersion: "24.04",
+						mongoVersion: &version8,
+						expectedSpec: &DownloadSpec{
+							version:  &version8,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "ubuntu2404",
+						},
+					},
+					"Ubuntu 24.04 with MongoDB 7.0": {
+						linuxId:      "ubuntu",
+						linuxVersion: "24.04",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "ubuntu2204",
+						},
+					},
+					"Ubuntu 22.04 with MongoDB 7.0": {
 						linuxId:      "ubuntu",
 						linuxVersion: "22.04",
+						mongoVersion: &version7,
 						expectedSpec: &DownloadSpec{
-							version:  &version,
+							version:  &version7,
 							Arch:     "x86_64",
 							Platform: "linux",
 							OSName:   "ubuntu2204",
 						},
 					},
+					"Ubuntu 22.04 with MongoDB 5.0": {
+						linuxId:      "ubuntu",
+						linuxVersion: "22.04",
+						expectedSpec: &DownloadSpec{
+							version:  &version,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "ubuntu2004",
+						},
+					},
 					"Ubuntu 20.04": {
 						linuxId:      "ubuntu",
 						linuxVersion: "20.04",
@@ -178,21 +231,61 @@ func TestMakeDownloadSpec(t *testing.T) {
 							OSName:   "ubuntu1604",
 						},
 					},
+					"Ubuntu 16.04 with MongoDB 7.0": {
+						linuxId:      "ubuntu",
+						linuxVersion: "16.04",
+						mongoVersion: &version7,
+						expectedErr:  &UnsupportedSystemError{msg: "no MongoDB 7.0 build for ubuntu version 16 on x86_64"},
+					},
+					"Linux Mint 21.2": {
+						linuxId:      "linuxmint",
+						linuxVersion: "21.2",
+						osRelease:    "ID_LIKE=\"ubuntu debian\"\nUBUNTU_CODENAME=jammy\n",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "ubuntu2204",
+						},
+					},
 					"Old Ubuntu": {
 						linuxId:      "ubuntu",
 						linuxVersion: "14.04",
 						expectedErr:  &UnsupportedSystemError{msg: "invalid ubuntu version 14 (min 16)"},
 					},
-					"Debian 12": {
+					"Debian 12 with MongoDB 7.0": {
 						linuxId:      "debian",
 						linuxVersion: "12",
+						mongoVersion: &version7,
 						expectedSpec: &DownloadSpec{
-							version:  &version,
+							version:  &version7,
 							Arch:     "x86_64",
 							Platform: "linux",
 							OSName:   "debian12",
 						},
 					},
+					"Debian 12 with MongoDB 5.0": {
+						linuxId:      "debian",
+						linuxVersion: "12",
+						expectedSpec: &DownloadSpec{
+							version:  &version,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "debian11",
+						},
+					},
+					"Debian 11": {
+						linuxId:      "debian",
+						linuxVersion: "11",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "debian11",
+						},
+					},
 					"Debian 10": {
 						linuxId:      "debian",
 						linuxVersion: "10",
@@ -228,15 +321,137 @@ func TestMakeDownloadSpec(t *testing.T) {
 							OSName:   "amazon2",
 						},
 					},
+					"Amazon Linux 2023": {
+						linuxId:      "amzn",
+						linuxVersion: "2023",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "amazon2023",
+						},
+					},
 					"Old Amazon Linux": {
 						linuxId:      "amzn",
 						linuxVersion: "1",
-						expectedErr:  &UnsupportedSystemError{msg: "invalid amazon linux version 1 (only 2)"},
+						expectedErr:  &UnsupportedSystemError{msg: "invalid amazon linux version 1 (min 2)"},
 					},
-					"Other Linux": {
+					"RHEL 9": {
+						linuxId:      "rhel",
+						linuxVersion: "9.3",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "rhel90",
+						},
+					},
+					"RHEL 7 with MongoDB 8.0": {
+						linuxId:      "rhel",
+						linuxVersion: "7.9",
+						mongoVersion: &version8,
+						expectedErr:  &UnsupportedSystemError{msg: "no MongoDB 8.0 build for rhel version 7 on x86_64"},
+					},
+					"Rocky Linux 8": {
+						linuxId:      "rocky",
+						linuxVersion: "8.9",
+						osRelease:    "ID_LIKE=\"rhel centos fedora\"\n",
+						expectedSpec: &DownloadSpec{
+							version:  &version,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "rhel80",
+						},
+					},
+					"AlmaLinux 9": {
+						linuxId:      "almalinux",
+						linuxVersion: "9.3",
+						osRelease:    "ID_LIKE=\"rhel centos fedora\"\n",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "rhel90",
+						},
+					},
+					"Fedora 39": {
 						linuxId:      "fedora",
-						linuxVersion: "17",
-						expectedErr:  &UnsupportedSystemError{msg: "invalid linux version 'fedora'"},
+						linuxVersion: "39",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "rhel90",
+						},
+					},
+					"SLES 15": {
+						linuxId:      "sles",
+						linuxVersion: "15.5",
+						osRelease:    "ID_LIKE=\"suse\"\n",
+						expectedSpec: &DownloadSpec{
+							version:  &version,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "suse15",
+						},
+					},
+					"openSUSE Leap 15": {
+						linuxId:      "opensuse-leap",
+						linuxVersion: "15.5",
+						osRelease:    "ID_LIKE=\"suse opensuse\"\n",
+						mongoVersion: &version7,
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "x86_64",
+							Platform: "linux",
+							OSName:   "suse15",
+						},
+					},
+					"Rocky Linux 8 on ARM": {
+						linuxId:      "rocky",
+						linuxVersion: "8.9",
+						osRelease:    "ID_LIKE=\"rhel centos fedora\"\n",
+						mongoVersion: &version7,
+						goArch:       "arm64",
+						expectedSpec: &DownloadSpec{
+							version:  &version7,
+							Arch:     "arm64",
+							Platform: "linux",
+							OSName:   "rhel82",
+						},
+					},
+					"RHEL 9 on ARM with MongoDB 5.0": {
+						linuxId:      "rhel",
+						linuxVersion: "9.3",
+						goArch:       "arm64",
+						expectedSpec: &DownloadSpec{
+							version:  &version,
+							Arch:     "arm64",
+							Platform: "linux",
+							OSName:   "rhel82",
+						},
+					},
+					"Debian 12 on ARM": {
+						linuxId:      "debian",
+						linuxVersion: "12",
+						mongoVersion: &version7,
+						goArch:       "arm64",
+						expectedErr:  &UnsupportedSystemError{msg: "no MongoDB 7.0 build for debian version 12 on arm64"},
+					},
+					"Other Linux": {
+						linuxId:      "arch",
+						linuxVersion: "20240101",
+						expectedErr:  &UnsupportedSystemError{msg: "invalid linux version 'arch'"},
+					},
+					"Other Linux like an unknown one": {
+						linuxId:      "gentoo",
+						linuxVersion: "2.14",
+						osRelease:    "ID_LIKE=\"unknown\"\n",
+						expectedErr:  &UnsupportedSystemError{msg: "invalid linux version 'gentoo'"},
 					},
 					"Invalid linux version": {
 						linuxId:      "fedora",
@@ -246,12 +461,19 @@ func TestMakeDownloadSpec(t *testing.T) {
 				}
 				for name, tc := range tests {
 					Convey(name, func() {
-						osrelease := fmt.Sprintf("ID=%s\nVERSION_ID=%s\n", tc.linuxId, tc.linuxVersion)
+						osrelease := fmt.Sprintf("ID=%s\nVERSION_ID=%s\n", tc.linuxId, tc.linuxVersion) + tc.osRelease
 						// We are using a memory backed file system
 						// and this will not affect a real file if it existed
 						afs.WriteFile(etcOsReleaseFileName, []byte(osrelease), 0744)
+						if tc.goArch != "" {
+							goArch = tc.goArch
+						}
+						mongoVersion := version
+						if tc.mongoVersion != nil {
+							mongoVersion = *tc.mongoVersion
+						}
 						Convey("Then the returned spec is correct", func() {
-							spec, err := MakeDownloadSpec(version)
+							spec, err := MakeDownloadSpec(mongoVersion)
 							if tc.expectedErr != nil {
 								So(err, ShouldBeError)
 								So(err, ShouldResemble, tc.expectedErr)
@@ -269,6 +491,25 @@ func TestMakeDownloadSpec(t *testing.T) {
 					})
 				}
 
+				Convey("When the distribution is given in the MIM_LINUX_DISTRO environment variable", func() {
+					afs.WriteFile(etcOsReleaseFileName, []byte("ID=ubuntu\nVERSION_ID=22.04\n"), 0744)
+					getEnv = func(key string) string {
+						if key == LinuxDistroEnv {
+							return "rhel80"
+						}
+						return ""
+					}
+					Reset(func() {
+						getEnv = originalGetEnv
+					})
+
+					Convey("Then it is used instead of the detected one", func() {
+						spec, err := MakeDownloadSpec(version)
+						So(err, ShouldBeNil)
+						So(spec.OSName, ShouldEqual, "rhel80")
+					})
+				})
+
 				Convey("When there is an error reading the os-release file", func() {
 					// We are using a memory backed file system
 					// and this will not affect a real file if it existed
@@ -316,3 +557,71 @@ func TestMakeDownloadSpec(t *testing.T) {
 		})
 	})
 }
+
+func TestNewDownloadSpec(t *testing.T) {
+	version := Version{Major: 7, Minor: 0, Patch: 4}
+
+	Convey("Given an explicit Linux platform", t, func() {
+		spec, err := NewDownloadSpec(version, "linux", "arm64", "ubuntu2204")
+
+		Convey("Then the spec is for that platform whatever the current system", func() {
+			So(err, ShouldBeNil)
+			path, err := spec.GetArtifactPath()
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, "linux/mongodb-linux-aarch64-ubuntu2204-7.0.4.tgz")
+		})
+	})
+
+	Convey("Given an explicit Linux build that is not published for the architecture", t, func() {
+		spec, err := NewDownloadSpec(version, "linux", "arm64", "rhel80")
+
+		Convey("Then an UnsupportedSystemError is returned", func() {
+			So(spec, ShouldBeNil)
+			So(err, ShouldResemble, &UnsupportedSystemError{msg: "linux build rhel80 not published for arm64"})
+		})
+	})
+
+	Convey("Given an explicit Linux platform with the aarch64 architecture", t, func() {
+		spec, err := NewDownloadSpec(version, "linux", "aarch64", "ubuntu2204")
+
+		Convey("Then it is the same as arm64", func() {
+			So(err, ShouldBeNil)
+			So(spec.Arch, ShouldEqual, "arm64")
+			path, err := spec.GetArtifactPath()
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, "linux/mongodb-linux-aarch64-ubuntu2204-7.0.4.tgz")
+		})
+	})
+
+	Convey("Given an explicit Mac platform", t, func() {
+		spec, err := NewDownloadSpec(version, "osx", "x86_64", "")
+
+		Convey("Then the spec is for that platform", func() {
+			So(err, ShouldBeNil)
+			path, err := spec.GetArtifactPath()
+			So(err, ShouldBeNil)
+			So(path, ShouldEqual, "osx/mongodb-macos-x86_64-7.0.4.tgz")
+		})
+	})
+
+	Convey("Given an invalid platform", t, func() {
+		for name, tc := range map[string]struct {
+			version  Version
+			platform string
+			arch     string
+			osName   string
+			err      error
+		}{
+			"Linux without OS name":       {version: version, platform: "linux", arch: "x86_64", err: errors.New("invalid spec: OS name not provided")},
+			"Unsupported platform":        {version: version, platform: "windows", arch: "x86_64", err: &UnsupportedSystemError{msg: "platform windows not supported"}},
+			"Unsupported architecture":    {version: version, platform: "linux", arch: "s390x", osName: "rhel80", err: &UnsupportedSystemError{msg: "architecture s390x not supported"}},
+			"Unsupported MongoDB version": {version: Version{Major: 4, Minor: 2, Patch: 0}, platform: "linux", arch: "x86_64", osName: "rhel80", err: &UnsupportedMongoVersionError{version: "4.2.0", msg: "only version 4.4 and above are supported"}},
+		} {
+			Convey("Then an error is returned for "+name, func() {
+				spec, err := NewDownloadSpec(tc.version, tc.platform, tc.arch, tc.osName)
+				So(spec, ShouldBeNil)
+				So(err, ShouldResemble, tc.err)
+			})
+		}
+	})
+}
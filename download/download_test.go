@@ -2,11 +2,16 @@ package download
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +20,7 @@ import (
 )
 
 func TestGetMongoDB(t *testing.T) {
+	pinTestKey(t)
 	const (
 		validMongodTarball   = "/mongodb-test.tgz"
 		invalidMongodTarball = "/random.tgz"
@@ -22,7 +28,8 @@ func TestGetMongoDB(t *testing.T) {
 		corrupted            = "/corrupted"
 	)
 
-	var testCtx context.Context
+	testCtx := context.Background()
+	originalGetMongoPublicKey := getMongoPublicKey
 
 	// Use a memory backed filesystem (no persistence)
 	afs = afero.Afero{Fs: afero.NewMemMapFs()}
@@ -42,13 +49,16 @@ func TestGetMongoDB(t *testing.T) {
 
 		cfg := new(Config)
 		cfg.cachePath = path.Join(tmpCache, "mongod")
+		cfg.source = NewHTTPSource(ts.URL, nil)
+		cfg.keySource = NewHTTPSource(ts.URL, nil)
 
 		Convey("When the mongod exec file is not in cache", func() {
-			afs.Remove(cfg.cachePath)
+			afs.Remove(cfg.MongoPath())
+			afs.Remove(cfg.MongosPath())
 			Convey("And the requested url exists", func() {
-				cfg.mongoUrl = ts.URL + validMongodTarball
+				cfg.artifact = validMongodTarball
 				Convey("And the appropriate key was used to sign the package", func() {
-					getMongoPublicKey = func(ctx context.Context, v Version) (afero.File, error) {
+					getMongoPublicKey = func(ctx context.Context, src Source, name string) (io.ReadCloser, error) {
 						return os.Open("testdata/key-correct.asc")
 					}
 					Convey("Then it downloads the tarball and stores the exec file in cache", func() {
@@ -57,49 +67,64 @@ func TestGetMongoDB(t *testing.T) {
 						err := GetMongoDB(testCtx, *cfg)
 						So(err, ShouldBeNil)
 
-						stat, err := afs.Stat(cfg.cachePath)
-						So(err, ShouldBeNil)
-						So(stat.Size(), ShouldBeGreaterThan, 0)
-						So(stat.Mode()&0100, ShouldNotBeZeroValue)
-						So(stat.ModTime(), ShouldHappenBetween, startTime, time.Now())
+						for _, binPath := range []string{cfg.MongoPath(), cfg.MongosPath()} {
+							stat, err := afs.Stat(binPath)
+							So(err, ShouldBeNil)
+							So(stat.Size(), ShouldBeGreaterThan, 0)
+							So(stat.Mode()&0100, ShouldNotBeZeroValue)
+							So(stat.ModTime(), ShouldHappenBetween, startTime, time.Now())
+						}
+
+						So(NewCacheAt(path.Dir(tmpCache)).Verify(CacheEntry{Path: tmpCache}), ShouldBeNil)
+					})
+					Convey("Then an ExtractionError is returned if a requested tool is not in the tarball", func() {
+						WithTools("mongo")(cfg)
+
+						err := GetMongoDB(testCtx, *cfg)
+						var extractionErr *ExtractionError
+						So(errors.As(err, &extractionErr), ShouldBeTrue)
+						So(extractionErr.Err, ShouldBeError, "did not find a mongo binary in the tar file")
 					})
 				})
 				Convey("And the wrong key was used to sign the package", func() {
-					getMongoPublicKey = func(ctx context.Context, v Version) (afero.File, error) {
+					getMongoPublicKey = func(ctx context.Context, src Source, name string) (io.ReadCloser, error) {
 						return os.Open("testdata/key-incorrect.asc")
 					}
 					Convey("Then an error is returned", func() {
 						err := GetMongoDB(testCtx, *cfg)
-						So(err, ShouldBeError)
-						So(err.Error(), ShouldEqual, "signature verification failed")
+						var sigErr *SignatureError
+						So(errors.As(err, &sigErr), ShouldBeTrue)
+						So(sigErr.URL, ShouldEqual, ts.URL+"/mongodb-test.tgz.sig")
 					})
 				})
 			})
 			Convey("And the requested url can not be found", func() {
-				cfg.mongoUrl = ts.URL + "/invalid"
-				Convey("Then an error is returned", func() {
+				cfg.artifact = "/invalid"
+				Convey("Then an ArtifactNotFoundError is returned", func() {
 					err := GetMongoDB(testCtx, *cfg)
-					So(err, ShouldBeError)
-					So(err.Error(), ShouldEqual, "invalid status code 404")
+					So(err, ShouldResemble, &ArtifactNotFoundError{URL: ts.URL + "/invalid", StatusCode: http.StatusNotFound})
+					So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
 				})
 			})
 			Convey("And the requested file's checksum can not be verified", func() {
-				cfg.mongoUrl = ts.URL + corrupted
-				Convey("Then an error is returned", func() {
+				cfg.artifact = corrupted
+				Convey("Then a ChecksumMismatchError is returned", func() {
 					err := GetMongoDB(testCtx, *cfg)
-					So(err, ShouldBeError)
-					So(err.Error(), ShouldEqual, "checksum verification failed")
+					var checksumErr *ChecksumMismatchError
+					So(errors.As(err, &checksumErr), ShouldBeTrue)
+					So(checksumErr.Name, ShouldEqual, ts.URL+"/corrupted")
+					So(checksumErr.Expected, ShouldNotEqual, checksumErr.Actual)
 				})
 			})
 			Convey("And the requested url is not a tarball", func() {
-				cfg.mongoUrl = ts.URL + notTarball
+				cfg.artifact = notTarball
 				Convey("Then an error is returned", func() {
 					err := GetMongoDB(testCtx, *cfg)
 					So(err, ShouldBeError)
 				})
 			})
 			Convey("And the requested url is a tarball not containing a mongod file", func() {
-				cfg.mongoUrl = ts.URL + invalidMongodTarball
+				cfg.artifact = invalidMongodTarball
 				Convey("Then an error is returned", func() {
 					err := GetMongoDB(testCtx, *cfg)
 					So(err, ShouldBeError)
@@ -107,21 +132,278 @@ func TestGetMongoDB(t *testing.T) {
 			})
 		})
 
-		Convey("When the mongod exec file is found in cache", func() {
-			afs.Create(cfg.cachePath)
+		Convey("When the mongod and mongos exec files are found in cache", func() {
+			afs.Create(cfg.MongoPath())
+			afs.Create(cfg.MongosPath())
+
+			Convey("Then it uses the files in cache and it does not download them again", func() {
+				cfg.artifact = "/should-not-be-called"
+
+				err := GetMongoDB(testCtx, *cfg)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Then it downloads the tarball again if a requested tool is not in the cache", func() {
+				cfg.artifact = "/should-not-be-called"
+				WithTools("mongo")(cfg)
+
+				err := GetMongoDB(testCtx, *cfg)
+				var notFoundErr *ArtifactNotFoundError
+				So(errors.As(err, &notFoundErr), ShouldBeTrue)
+			})
+		})
+
+		Convey("When only the mongod exec file is found in cache", func() {
+			afs.Create(cfg.MongoPath())
 
-			Convey("Then it uses the file in cache and it does not download it again", func() {
-				cfg.mongoUrl = ts.URL + "/should-not-be-called"
+			Convey("Then it uses the file in cache, as mongos is only required if requested", func() {
+				cfg.artifact = "/should-not-be-called"
 
 				err := GetMongoDB(testCtx, *cfg)
 				So(err, ShouldBeNil)
 			})
+
+			Convey("Then it downloads the tarball again if mongos is requested", func() {
+				cfg.artifact = "/should-not-be-called"
+				WithTools("mongos")(cfg)
+
+				err := GetMongoDB(testCtx, *cfg)
+				var notFoundErr *ArtifactNotFoundError
+				So(errors.As(err, &notFoundErr), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the offline mode is enabled and the version is not in cache", func() {
+			cacheFolder := path.Join(tmpCache, folderName)
+			for _, name := range []string{"mongodb-linux-x86_64-5.0.2.tgz", "mongodb-linux-x86_64-4.4.8.tgz", "mongodb-macos-x86_64-6.0.1.tgz"} {
+				afs.MkdirAll(path.Join(cacheFolder, name), 0755)
+				afs.Create(path.Join(cacheFolder, name, "mongod"))
+			}
+			afs.Create(path.Join(cacheFolder, "mongodb-linux-x86_64-4.4.8.tgz", "mongo"))
+			afs.MkdirAll(path.Join(cacheFolder, "mongodb-linux-x86_64-6.0.0.tgz"), 0755)
+
+			offlineCfg := Config{
+				mongoVersion: Version{Major: 7, Minor: 0, Patch: 1},
+				artifact:     "should-not-be-called",
+				source:       NewHTTPSource(ts.URL, nil),
+				cachePath:    path.Join(cacheFolder, "mongodb-linux-x86_64-7.0.1.tgz", "mongod"),
+				offline:      true,
+			}
+
+			Convey("Then a NotCachedError listing the versions cached for the platform is returned", func() {
+				err := GetMongoDB(testCtx, offlineCfg)
+				So(err, ShouldResemble, &NotCachedError{Product: "MongoDB", Version: "7.0.1", Available: []string{"4.4.8", "5.0.2"}})
+				So(err.Error(), ShouldEqual, `MongoDB version "7.0.1" not found in cache and offline mode is enabled; cached versions: 4.4.8, 5.0.2`)
+			})
+
+			Convey("Then the cached versions listed are those holding the requested tools", func() {
+				offlineCfg.tools = []string{"mongo"}
+				err := GetMongoDB(testCtx, offlineCfg)
+				So(err, ShouldResemble, &NotCachedError{Product: "MongoDB", Version: "7.0.1", Available: []string{"4.4.8"}})
+			})
+
+			Convey("Then a version is not listed if it is not cached with the requested tools", func() {
+				offlineCfg.cachePath = path.Join(cacheFolder, "mongodb-linux-x86_64-5.0.2.tgz", "mongod")
+				offlineCfg.mongoVersion = Version{Major: 5, Minor: 0, Patch: 2}
+				offlineCfg.tools = []string{"mongo"}
+				err := GetMongoDB(testCtx, offlineCfg)
+				So(err, ShouldResemble, &NotCachedError{Product: "MongoDB", Version: "5.0.2", Available: []string{"4.4.8"}})
+			})
+
+			Reset(func() {
+				afs.RemoveAll(cacheFolder)
+			})
 		})
 
 		Reset(func() {
 			ts.Close()
-			afs.Remove(cfg.cachePath)
+			getMongoPublicKey = originalGetMongoPublicKey
+			afs.Remove(cfg.MongoPath())
+			afs.Remove(cfg.MongosPath())
+		})
+
+	})
+}
+
+func TestExtractMongoBins(t *testing.T) {
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a MongoDB tarball", t, func() {
+		tgz, err := os.Open("testdata/mongodb-test.tgz")
+		So(err, ShouldBeNil)
+		defer tgz.Close()
+
+		Convey("When the mongod and mongos binaries are extracted", func() {
+			files, err := extractMongoBins(testCtx, tgz, []string{"mongod", "mongos"}, io.Discard)
+
+			Convey("Then both are extracted to executable files", func() {
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 2)
+				for _, name := range []string{"mongod", "mongos"} {
+					stat, err := afs.Stat(files[name])
+					So(err, ShouldBeNil)
+					So(stat.Size(), ShouldBeGreaterThan, 0)
+					So(stat.Mode()&0100, ShouldNotBeZeroValue)
+				}
+			})
+		})
+
+		Convey("When a binary not in the tarball is extracted", func() {
+			files, err := extractMongoBins(testCtx, tgz, []string{"mongod", "mongo"}, io.Discard)
+
+			Convey("Then an error is returned", func() {
+				So(files, ShouldBeNil)
+				So(err, ShouldBeError, "did not find a mongo binary in the tar file")
+			})
+		})
+	})
+}
+
+func TestDownloadFile(t *testing.T) {
+	testCtx := context.Background()
+	content := strings.Repeat("mongodb", 1000)
+	originalRetryBaseDelay := retryBaseDelay
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a server", t, func() {
+		retryBaseDelay = time.Millisecond
+		var requests []*http.Request
+		var handler func(w http.ResponseWriter, r *http.Request)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			handler(w, r)
+		}))
+		src := NewHTTPSource(ts.URL, nil)
+
+		Convey("When the connection drops in the middle of the transfer", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if len(requests) == 1 {
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					_, _ = io.WriteString(w, content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+			}
+
+			file, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then the transfer is resumed from where it stopped", func() {
+				So(err, ShouldBeNil)
+				defer file.Close()
+				So(requests, ShouldHaveLength, 2)
+				So(requests[1].Header.Get("Range"), ShouldEqual, fmt.Sprintf("bytes=%d-", len(content)/2))
+
+				b, _ := io.ReadAll(file)
+				So(string(b), ShouldEqual, content)
+			})
+		})
+
+		Convey("When the server ignores the range of a resumed transfer", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if len(requests) == 1 {
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					_, _ = io.WriteString(w, content[:len(content)/2])
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+				_, _ = io.WriteString(w, content)
+			}
+
+			file, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then the whole content is downloaded again", func() {
+				So(err, ShouldBeNil)
+				defer file.Close()
+				So(requests, ShouldHaveLength, 2)
+
+				b, _ := io.ReadAll(file)
+				So(string(b), ShouldEqual, content)
+			})
+		})
+
+		Convey("When the server is temporarily unavailable", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				if len(requests) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = io.WriteString(w, content)
+			}
+
+			file, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then the download is retried until it succeeds", func() {
+				So(err, ShouldBeNil)
+				defer file.Close()
+				So(requests, ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("When the server keeps failing", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+
+			_, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then the download is given up after the maximum number of attempts", func() {
+				So(err, ShouldResemble, &HTTPStatusError{URL: src.Location("file"), StatusCode: http.StatusBadGateway})
+				So(requests, ShouldHaveLength, maxDownloadAttempts)
+			})
+		})
+
+		Convey("When the server denies access to the file", func() {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+			}
+
+			_, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then a HTTPStatusError that is not temporary is returned without retrying", func() {
+				var statusErr *HTTPStatusError
+				So(errors.As(err, &statusErr), ShouldBeTrue)
+				So(statusErr.StatusCode, ShouldEqual, http.StatusForbidden)
+				So(statusErr.Temporary(), ShouldBeFalse)
+				So(requests, ShouldHaveLength, 1)
+			})
 		})
 
+		Convey("When the file does not exist", func() {
+			handler = http.NotFound
+
+			_, err := downloadFile(testCtx, src, "file", nil)
+
+			Convey("Then the download is not retried", func() {
+				So(err, ShouldResemble, &ArtifactNotFoundError{URL: src.Location("file"), StatusCode: http.StatusNotFound})
+				So(requests, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When the context is cancelled during the download", func() {
+			retryBaseDelay = time.Hour
+			ctx, cancel := context.WithCancel(testCtx)
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				cancel()
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+
+			_, err := downloadFile(ctx, src, "file", nil)
+
+			Convey("Then the download is given up", func() {
+				So(errors.Is(err, context.Canceled), ShouldBeTrue)
+				So(requests, ShouldHaveLength, 1)
+			})
+		})
+
+		Reset(func() {
+			ts.Close()
+			retryBaseDelay = originalRetryBaseDelay
+		})
 	})
 }
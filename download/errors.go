@@ -1,5 +1,12 @@
 package download
 
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
 // UnsupportedSystemError is used to indicate that we do not support
 // automatic selection of the right MongoDB binary for your system
 type UnsupportedSystemError struct {
@@ -20,3 +27,147 @@ type UnsupportedMongoVersionError struct {
 func (err *UnsupportedMongoVersionError) Error() string {
 	return "unsupported MongoDB version \"" + err.version + "\": " + err.msg
 }
+
+// UnsupportedToolError is used to indicate that a tool given by WithTools
+// is not shipped with the MongoDB server tarball of the requested version
+type UnsupportedToolError struct {
+	// Name is the name of the tool
+	Name string
+	msg  string
+}
+
+func (err *UnsupportedToolError) Error() string {
+	return "unsupported tool \"" + err.Name + "\": " + err.msg
+}
+
+// NotCachedError is used to indicate that the requested version of MongoDB, or of the MongoDB Database Tools,
+// is not in the cache, and can not be downloaded as the offline mode is enabled
+type NotCachedError struct {
+	// Product is what was requested, "MongoDB" or "MongoDB Database Tools"
+	Product string
+	// Version is the requested version
+	Version string
+	// Available lists the versions available in the cache
+	Available []string
+}
+
+func (err *NotCachedError) Error() string {
+	available := "none"
+	if len(err.Available) > 0 {
+		available = strings.Join(err.Available, ", ")
+	}
+	return err.Product + " version \"" + err.Version + "\" not found in cache and offline mode is enabled; cached versions: " + available
+}
+
+// KeyMismatchError is used to indicate that a MongoDB release was not signed
+// with the key pinned for its release series
+type KeyMismatchError struct {
+	// Series is the release series, e.g. 7.0
+	Series string
+	// Expected is the fingerprint of the pinned key
+	Expected string
+	// Actual is the fingerprint of the key the release was signed with
+	Actual string
+}
+
+func (err *KeyMismatchError) Error() string {
+	return fmt.Sprintf("MongoDB %s signing key mismatch: expected fingerprint %s, got %s", err.Series, err.Expected, err.Actual)
+}
+
+// UnknownKeyError is used to indicate that there is no pinned key for the release series of MongoDB,
+// and trust on first use is not enabled
+type UnknownKeyError struct {
+	// Series is the release series, e.g. 8.2, or the other product the key signs, e.g. mongosh
+	Series string
+}
+
+func (err *UnknownKeyError) Error() string {
+	return "no pinned signing key for MongoDB " + err.Series + " and trust on first use is not enabled"
+}
+
+// ArtifactNotFoundError is used to indicate that an artifact (e.g. a tarball, or its checksum or signature)
+// does not exist in the download source. It matches fs.ErrNotExist with errors.Is
+type ArtifactNotFoundError struct {
+	// URL is the location of the artifact in the source
+	URL string
+	// StatusCode is the HTTP status code of the response, or 0 if the source is not an HTTP one
+	StatusCode int
+}
+
+func (err *ArtifactNotFoundError) Error() string {
+	if err.StatusCode == 0 {
+		return "artifact not found: " + err.URL
+	}
+	return fmt.Sprintf("artifact not found: %s (status code %d)", err.URL, err.StatusCode)
+}
+
+func (err *ArtifactNotFoundError) Unwrap() error {
+	return fs.ErrNotExist
+}
+
+// HTTPStatusError is used to indicate that an HTTP source did not respond with the requested content,
+// for another reason than the artifact not existing (see ArtifactNotFoundError)
+type HTTPStatusError struct {
+	// URL is the location of the artifact in the source
+	URL string
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+}
+
+func (err *HTTPStatusError) Error() string {
+	return fmt.Sprintf("invalid status code %d for %s", err.StatusCode, err.URL)
+}
+
+// Temporary reports whether the status code is worth retrying the request for, e.g. 503 or 429
+func (err *HTTPStatusError) Temporary() bool {
+	return err.StatusCode >= 500 || err.StatusCode == http.StatusTooManyRequests || err.StatusCode == http.StatusRequestTimeout
+}
+
+// ChecksumMismatchError is used to indicate that the SHA256 checksum of a file
+// is not the one published, or recorded in the cache manifest
+type ChecksumMismatchError struct {
+	// Name is the location of the file checked
+	Name string
+	// Expected is the checksum published or recorded
+	Expected string
+	// Actual is the checksum of the file
+	Actual string
+}
+
+func (err *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum verification failed for %s: expected %s, got %s", err.Name, err.Expected, err.Actual)
+}
+
+// SignatureError is used to indicate that the signature of a MongoDB tarball
+// could not be verified with the MongoDB public key
+type SignatureError struct {
+	// URL is the location of the signature in the source
+	URL string
+	// Err is the reason the verification failed
+	Err error
+}
+
+func (err *SignatureError) Error() string {
+	return fmt.Sprintf("signature verification failed for %s: %v", err.URL, err.Err)
+}
+
+func (err *SignatureError) Unwrap() error {
+	return err.Err
+}
+
+// ExtractionError is used to indicate that the binaries could not be extracted from a MongoDB tarball,
+// e.g. because it is not a valid tarball or does not contain them
+type ExtractionError struct {
+	// URL is the location of the tarball in the source
+	URL string
+	// Err is the reason the extraction failed
+	Err error
+}
+
+func (err *ExtractionError) Error() string {
+	return fmt.Sprintf("error extracting MongoDB binaries from %s: %v", err.URL, err.Err)
+}
+
+func (err *ExtractionError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,45 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrors(t *testing.T) {
+	Convey("Given download errors wrapped by the caller", t, func() {
+		cause := errors.New("gzip: invalid header")
+		notFound := fmt.Errorf("download failed: %w", &ArtifactNotFoundError{URL: "https://fastdl.mongodb.org/linux/x.tgz", StatusCode: 404})
+		extraction := fmt.Errorf("install failed: %w", &ExtractionError{URL: "https://fastdl.mongodb.org/linux/x.tgz", Err: cause})
+		signature := fmt.Errorf("install failed: %w", &SignatureError{URL: "https://fastdl.mongodb.org/linux/x.tgz.sig", Err: cause})
+
+		Convey("Then they can be inspected with errors.As", func() {
+			var notFoundErr *ArtifactNotFoundError
+			So(errors.As(notFound, &notFoundErr), ShouldBeTrue)
+			So(notFoundErr.StatusCode, ShouldEqual, 404)
+
+			var extractionErr *ExtractionError
+			So(errors.As(extraction, &extractionErr), ShouldBeTrue)
+			So(extractionErr.URL, ShouldEqual, "https://fastdl.mongodb.org/linux/x.tgz")
+
+			var signatureErr *SignatureError
+			So(errors.As(signature, &signatureErr), ShouldBeTrue)
+			So(errors.As(signature, &extractionErr), ShouldBeFalse)
+		})
+
+		Convey("Then their causes can be inspected with errors.Is", func() {
+			So(errors.Is(notFound, fs.ErrNotExist), ShouldBeTrue)
+			So(errors.Is(extraction, cause), ShouldBeTrue)
+			So(errors.Is(signature, cause), ShouldBeTrue)
+		})
+
+		Convey("Then their messages describe them", func() {
+			So(notFound.Error(), ShouldEqual, "download failed: artifact not found: https://fastdl.mongodb.org/linux/x.tgz (status code 404)")
+			So(extraction.Error(), ShouldEqual, "install failed: error extracting MongoDB binaries from https://fastdl.mongodb.org/linux/x.tgz: gzip: invalid header")
+			So((&ChecksumMismatchError{Name: "x.tgz", Expected: "aa", Actual: "bb"}).Error(), ShouldEqual, "checksum verification failed for x.tgz: expected aa, got bb")
+		})
+	})
+}
@@ -0,0 +1,66 @@
+//go:build ignore
+
+// fetchkeys downloads the public keys of the given MongoDB release series into the keys directory,
+// to be bundled in the package, and prints their fingerprints to check them against the published ones
+//
+// Usage: go run fetchkeys.go 7.0 8.0
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+const keysURL = "https://pgp.mongodb.com"
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run fetchkeys.go <major>.<minor>...")
+		os.Exit(2)
+	}
+
+	for _, series := range os.Args[1:] {
+		if err := fetchKey(series); err != nil {
+			fmt.Fprintf(os.Stderr, "could not fetch the key of series %s: %v\n", series, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// fetchKey downloads the key of the series into keys/server-<major>.<minor>.asc
+func fetchKey(series string) error {
+	name := "server-" + series + ".asc"
+	resp, err := http.Get(keysURL + "/" + name)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("invalid status code %d", resp.StatusCode)
+	}
+	armored, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	if err != nil {
+		return err
+	}
+	if len(keyring) == 0 {
+		return fmt.Errorf("no key in %s", name)
+	}
+
+	if err = os.WriteFile(path.Join("keys", name), armored, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %X\n", name, keyring[0].PrimaryKey.Fingerprint)
+	return nil
+}
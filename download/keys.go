@@ -0,0 +1,132 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/ONSdigital/log.go/v2/log"
+	"golang.org/x/crypto/openpgp"
+)
+
+// TrustOnFirstUseEnv is the environment variable that enables trust on first use when set to a true value
+// (e.g. "true" or "1"): the key downloaded for a release series with no pinned key is accepted, and kept in the cache
+// to verify the later downloads of the series
+const TrustOnFirstUseEnv = "MIM_TRUST_ON_FIRST_USE"
+
+//go:generate go run fetchkeys.go 4.4 5.0 6.0 7.0 8.0
+
+//go:embed keys
+var embeddedKeys embed.FS
+
+// bundledKeys holds the public keys embedded in the package, as keys/server-<major>.<minor>.asc
+// We define it as a package var so we can override it in tests
+var bundledKeys fs.FS = embeddedKeys
+
+// pinnedKeyFingerprints maps the MongoDB release series (Major.Minor) to the fingerprint of the primary key
+// their releases are signed with, as published in the MongoDB installation instructions
+// We define it as a package var so we can override it in tests
+var pinnedKeyFingerprints = map[string]string{
+	"4.4": "20691EEC35216C63CAF66CE1656408E390CFB1F5",
+	"5.0": "F5679A222C647C87527C2F8CB00A0BD1E2C63C11",
+	"6.0": "39BD841E4BE5FB195A65400E6A26B1AE64C3C388",
+	"7.0": "E58830201F7DD82CD808AA84160D26BB1785BA38",
+	"8.0": "4B0752C1BCA238C0B4EE14DC41DE058A4E7DCA05",
+}
+
+// signingKey is the public key a release is expected to be signed with
+type signingKey struct {
+	// series is the release series, e.g. 7.0
+	series string
+	// fingerprint is the pinned fingerprint of the key, or "" if the series has none (trust on first use)
+	fingerprint string
+	// armored is the armored public key
+	armored []byte
+	// trustedPath is where the key is to be kept once trusted on first use, or "" if it needs not be
+	trustedPath string
+}
+
+// keySeries returns the release series of a version, which the signing keys are published for
+func keySeries(v Version) string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// signingKeyFor returns the key the given version is expected to be signed with:
+// - for a series with a pinned key, the bundled key if any, or else the downloaded one
+// - for other series, if trust on first use is enabled, the key trusted on a previous download if any,
+// or else the downloaded one
+func (cfg *Config) signingKeyFor(ctx context.Context) (*signingKey, error) {
+	series := keySeries(cfg.mongoVersion)
+	return cfg.signingKeyNamed(ctx, series, "server-"+series)
+}
+
+// signingKeyNamed returns the public key named <name>.asc, pinned for the given series
+// (a MongoDB release series, or another product such as mongosh), as described for signingKeyFor
+func (cfg *Config) signingKeyNamed(ctx context.Context, series, name string) (*signingKey, error) {
+	keyName := name + ".asc"
+	key := &signingKey{series: series, fingerprint: pinnedKeyFingerprints[series]}
+
+	switch {
+	case key.fingerprint != "":
+		armored, err := fs.ReadFile(bundledKeys, path.Join("keys", keyName))
+		if err == nil {
+			key.armored = armored
+			return key, nil
+		}
+	case !cfg.trustOnFirstUse:
+		return nil, &UnknownKeyError{Series: series}
+	default:
+		cacheDir, err := cfg.cacheDir()
+		if err != nil {
+			return nil, err
+		}
+		trustedPath := path.Join(cacheDir, "keys", keyName)
+		if armored, err := afs.ReadFile(trustedPath); err == nil {
+			key.armored = armored
+			return key, nil
+		}
+		log.Warn(ctx, "No pinned key for the MongoDB release series, trusting the downloaded one on first use", log.Data{"series": series})
+		key.trustedPath = trustedPath
+	}
+
+	keyFile, err := getMongoPublicKey(ctx, cfg.keySource, name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = keyFile.Close()
+	}()
+
+	if key.armored, err = io.ReadAll(keyFile); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// keyring returns the keyring made of the key
+func (key *signingKey) keyring() (openpgp.EntityList, error) {
+	return openpgp.ReadArmoredKeyRing(bytes.NewReader(key.armored))
+}
+
+// checkSigner checks that the signature was made by the pinned key, if any,
+// and keeps the key trusted on first use, if it needs to be
+func (key *signingKey) checkSigner(ctx context.Context, fingerprint string) error {
+	if key.fingerprint != "" && fingerprint != key.fingerprint {
+		return &KeyMismatchError{Series: key.series, Expected: key.fingerprint, Actual: fingerprint}
+	}
+
+	if key.trustedPath != "" {
+		if err := afs.MkdirAll(path.Dir(key.trustedPath), 0755); err != nil {
+			return err
+		}
+		if err := afs.WriteFile(key.trustedPath, key.armored, 0644); err != nil {
+			return err
+		}
+		log.Info(ctx, "MongoDB signing key trusted on first use", log.Data{"series": key.series, "key": fingerprint, "filename": key.trustedPath})
+	}
+	return nil
+}
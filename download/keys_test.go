@@ -0,0 +1,153 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// testKeyFingerprint is the fingerprint of testdata/key-correct.asc, the key the test tarball is signed with
+const testKeyFingerprint = "1D53822CEFF4CCF2257B00DBDCA19C265CD44343"
+
+// pinTestKey pins the test key for the versions used by the tests, with no bundled keys, until the test ends
+func pinTestKey(t *testing.T) {
+	originalPinnedKeyFingerprints := pinnedKeyFingerprints
+	originalBundledKeys := bundledKeys
+	pinnedKeyFingerprints = map[string]string{"0.0": testKeyFingerprint, "5.0": testKeyFingerprint}
+	bundledKeys = fstest.MapFS{}
+	t.Cleanup(func() {
+		pinnedKeyFingerprints = originalPinnedKeyFingerprints
+		bundledKeys = originalBundledKeys
+	})
+}
+
+func TestBundledKeys(t *testing.T) {
+	Convey("Given the keys bundled in the package", t, func() {
+		names, err := fs.Glob(embeddedKeys, "keys/server-*.asc")
+		So(err, ShouldBeNil)
+
+		Convey("Then each of them has the fingerprint pinned for its series", func() {
+			for _, name := range names {
+				series := strings.TrimSuffix(strings.TrimPrefix(path.Base(name), "server-"), ".asc")
+				key := &signingKey{series: series}
+				key.armored, err = fs.ReadFile(embeddedKeys, name)
+				So(err, ShouldBeNil)
+				keyring, err := key.keyring()
+				So(err, ShouldBeNil)
+				So(keyring, ShouldNotBeEmpty)
+				So(fmt.Sprintf("%X", keyring[0].PrimaryKey.Fingerprint), ShouldEqual, pinnedKeyFingerprints[series])
+			}
+		})
+	})
+}
+
+func TestVerifySignatureKeys(t *testing.T) {
+	testCtx := context.Background()
+	originalGetMongoPublicKey := getMongoPublicKey
+	pinTestKey(t)
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a tarball signed with the test key", t, func() {
+		pinnedKeyFingerprints = map[string]string{"5.0": testKeyFingerprint}
+		bundledKeys = fstest.MapFS{}
+		So(copyToAfs("testdata/mongodb-test.tgz", "/mirror/linux/mongodb-test.tgz"), ShouldBeNil)
+		So(copyToAfs("testdata/mongodb-test.tgz.sig", "/mirror/linux/mongodb-test.tgz.sig"), ShouldBeNil)
+		cacheRoot, _ := afs.TempDir("", "")
+
+		keyDownloads := 0
+		getMongoPublicKey = func(ctx context.Context, src Source, name string) (io.ReadCloser, error) {
+			keyDownloads++
+			return os.Open("testdata/key-correct.asc")
+		}
+		cfg := Config{
+			mongoVersion: Version{Major: 5, Minor: 0, Patch: 2},
+			artifact:     "linux/mongodb-test.tgz",
+			source:       NewDirSource("/mirror"),
+			cacheRoot:    cacheRoot,
+		}
+
+		Reset(func() {
+			getMongoPublicKey = originalGetMongoPublicKey
+		})
+
+		Convey("When the key pinned for the series is bundled", func() {
+			key, err := os.ReadFile("testdata/key-correct.asc")
+			So(err, ShouldBeNil)
+			bundledKeys = fstest.MapFS{"keys/server-5.0.asc": {Data: key}}
+			fingerprint, err := verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+
+			Convey("Then the signature is verified without downloading the key", func() {
+				So(err, ShouldBeNil)
+				So(fingerprint, ShouldEqual, testKeyFingerprint)
+				So(keyDownloads, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the key pinned for the series is not bundled", func() {
+			fingerprint, err := verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+
+			Convey("Then the signature is verified with the downloaded key", func() {
+				So(err, ShouldBeNil)
+				So(fingerprint, ShouldEqual, testKeyFingerprint)
+				So(keyDownloads, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When another key is pinned for the series", func() {
+			pinnedKeyFingerprints["5.0"] = "F5679A222C647C87527C2F8CB00A0BD1E2C63C11"
+			_, err := verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+
+			Convey("Then a KeyMismatchError giving the expected fingerprint is returned", func() {
+				So(err, ShouldResemble, &KeyMismatchError{
+					Series:   "5.0",
+					Expected: "F5679A222C647C87527C2F8CB00A0BD1E2C63C11",
+					Actual:   testKeyFingerprint,
+				})
+				So(err.Error(), ShouldEqual, "MongoDB 5.0 signing key mismatch: expected fingerprint F5679A222C647C87527C2F8CB00A0BD1E2C63C11, got "+testKeyFingerprint)
+			})
+		})
+
+		Convey("When no key is pinned for the series", func() {
+			delete(pinnedKeyFingerprints, "5.0")
+
+			Convey("And trust on first use is not enabled", func() {
+				_, err := verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+
+				Convey("Then an UnknownKeyError is returned without downloading the key", func() {
+					So(err, ShouldResemble, &UnknownKeyError{Series: "5.0"})
+					So(keyDownloads, ShouldEqual, 0)
+				})
+			})
+
+			Convey("And trust on first use is enabled", func() {
+				cfg.trustOnFirstUse = true
+				fingerprint, err := verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+
+				Convey("Then the downloaded key is trusted and kept in the cache", func() {
+					So(err, ShouldBeNil)
+					So(fingerprint, ShouldEqual, testKeyFingerprint)
+					So(keyDownloads, ShouldEqual, 1)
+					exists, _ := afs.Exists(path.Join(cacheRoot, "keys", "server-5.0.asc"))
+					So(exists, ShouldBeTrue)
+				})
+
+				Convey("And the key is used again for the next verification", func() {
+					_, err = verifySignature(testCtx, cfg, "/mirror/linux/mongodb-test.tgz")
+					So(err, ShouldBeNil)
+					So(keyDownloads, ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,171 @@
+package download
+
+import "fmt"
+
+// linuxBuild is a MongoDB build for a Linux distribution
+type linuxBuild struct {
+	// name is the name of the build in the MongoDB artifact names, e.g. ubuntu2204
+	name string
+	// minOSVersion is the lowest major version of the distribution the build is used for
+	minOSVersion int
+	// firstSeries is the first MongoDB release series (Major.Minor) the build is published for
+	firstSeries string
+	// lastSeries is the last MongoDB release series the build is published for, or "" if it still is
+	lastSeries string
+	// arch is the only architecture (x86_64 or arm64) the build is published for, or "" if it is for both
+	arch string
+}
+
+// publishedFor reports whether the build is published for the given MongoDB version
+func (b *linuxBuild) publishedFor(v Version) bool {
+	return compareSeries(v, b.firstSeries) >= 0 && (b.lastSeries == "" || compareSeries(v, b.lastSeries) <= 0)
+}
+
+// builtFor reports whether the build is published for the given architecture
+func (b *linuxBuild) builtFor(arch string) bool {
+	return b.arch == "" || b.arch == arch
+}
+
+// compareSeries compares the release series (Major.Minor) of a version with the given one
+func compareSeries(v Version, series string) int {
+	s, _ := parseBound(series)
+	return (&Version{Major: v.Major, Minor: v.Minor}).Compare(s)
+}
+
+// linuxDistro describes the MongoDB builds for a Linux distribution
+type linuxDistro struct {
+	// name is the name of the distribution in error messages
+	name string
+	// codenameKey is the os-release key giving the codename of the distribution version to derivatives, if any
+	codenameKey string
+	// builds are the MongoDB builds for the distribution, newest distribution version first
+	builds []linuxBuild
+}
+
+// build returns the name of the newest MongoDB build of the given MongoDB version
+// for the given major version of the distribution and architecture
+func (d *linuxDistro) build(osVersion int, arch string, mongoVersion Version) (string, error) {
+	minOSVersion := d.builds[len(d.builds)-1].minOSVersion
+	if osVersion < minOSVersion {
+		return "", &UnsupportedSystemError{msg: fmt.Sprintf("invalid %s version %d (min %d)", d.name, osVersion, minOSVersion)}
+	}
+
+	for _, b := range d.builds {
+		if osVersion >= b.minOSVersion && b.builtFor(arch) && b.publishedFor(mongoVersion) {
+			return b.name, nil
+		}
+	}
+	return "", &UnsupportedSystemError{
+		msg: fmt.Sprintf("no MongoDB %d.%d build for %s version %d on %s", mongoVersion.Major, mongoVersion.Minor, d.name, osVersion, arch),
+	}
+}
+
+// newestBuild returns the name of the newest MongoDB build for the given major version of the distribution
+// and architecture, whatever the MongoDB release series
+func (d *linuxDistro) newestBuild(osVersion int, arch string) (string, error) {
+	minOSVersion := d.builds[len(d.builds)-1].minOSVersion
+	if osVersion < minOSVersion {
+		return "", &UnsupportedSystemError{msg: fmt.Sprintf("invalid %s version %d (min %d)", d.name, osVersion, minOSVersion)}
+	}
+
+	for _, b := range d.builds {
+		if osVersion >= b.minOSVersion && b.builtFor(arch) {
+			return b.name, nil
+		}
+	}
+	return "", &UnsupportedSystemError{msg: fmt.Sprintf("no MongoDB build for %s version %d on %s", d.name, osVersion, arch)}
+}
+
+// linuxBuildFor reports whether the named Linux build is published for the given architecture.
+// Builds that are not in linuxDistros (e.g. given with MIM_LINUX_DISTRO) are assumed to be
+func linuxBuildFor(name, arch string) bool {
+	known := false
+	for _, distro := range linuxDistros {
+		for _, b := range distro.builds {
+			if b.name != name {
+				continue
+			}
+			if b.builtFor(arch) {
+				return true
+			}
+			known = true
+		}
+	}
+	return !known
+}
+
+// rhelBuilds are the MongoDB builds for Red Hat Enterprise Linux, also used for its rebuilds
+// (e.g. Rocky Linux, AlmaLinux, CentOS, Oracle Linux). The ARM builds for RHEL 8 are named rhel82
+var rhelBuilds = []linuxBuild{
+	{name: "rhel90", minOSVersion: 9, firstSeries: "6.0"},
+	{name: "rhel82", minOSVersion: 8, firstSeries: "4.4", arch: "arm64"},
+	{name: "rhel80", minOSVersion: 8, firstSeries: "4.4", arch: "x86_64"},
+	{name: "rhel70", minOSVersion: 7, firstSeries: "4.4", lastSeries: "7.0", arch: "x86_64"},
+}
+
+// linuxDistros maps the os-release IDs of the Linux distributions to their MongoDB builds.
+// Distributions not listed here are looked up by the IDs they are like (os-release ID_LIKE),
+// e.g. rocky and almalinux are like rhel, linuxmint is like ubuntu, sles and opensuse-leap are like suse
+var linuxDistros = map[string]linuxDistro{
+	"ubuntu": {
+		name:        "ubuntu",
+		codenameKey: "UBUNTU_CODENAME",
+		builds: []linuxBuild{
+			{name: "ubuntu2404", minOSVersion: 24, firstSeries: "8.0"},
+			{name: "ubuntu2204", minOSVersion: 22, firstSeries: "6.0"},
+			{name: "ubuntu2004", minOSVersion: 20, firstSeries: "4.4"},
+			{name: "ubuntu1804", minOSVersion: 18, firstSeries: "4.4", lastSeries: "6.0"},
+			{name: "ubuntu1604", minOSVersion: 16, firstSeries: "4.4", lastSeries: "5.0"},
+		},
+	},
+	// MongoDB publishes no ARM builds for Debian and SUSE
+	"debian": {
+		name:        "debian",
+		codenameKey: "DEBIAN_CODENAME",
+		builds: []linuxBuild{
+			{name: "debian12", minOSVersion: 12, firstSeries: "7.0", arch: "x86_64"},
+			{name: "debian11", minOSVersion: 11, firstSeries: "5.0", arch: "x86_64"},
+			{name: "debian10", minOSVersion: 10, firstSeries: "4.4", lastSeries: "6.0", arch: "x86_64"},
+			{name: "debian92", minOSVersion: 9, firstSeries: "4.4", lastSeries: "5.0", arch: "x86_64"},
+		},
+	},
+	"rhel": {name: "rhel", builds: rhelBuilds},
+	"ol":   {name: "oracle linux", builds: rhelBuilds},
+	// Fedora has no MongoDB builds of its own: the RHEL builds are used, RHEL 8 and 9 being based on Fedora 28 and 34
+	"fedora": {
+		name: "fedora",
+		builds: []linuxBuild{
+			{name: "rhel90", minOSVersion: 34, firstSeries: "6.0"},
+			{name: "rhel82", minOSVersion: 28, firstSeries: "4.4", arch: "arm64"},
+			{name: "rhel80", minOSVersion: 28, firstSeries: "4.4", arch: "x86_64"},
+		},
+	},
+	"amzn": {
+		name: "amazon linux",
+		builds: []linuxBuild{
+			{name: "amazon2023", minOSVersion: 2023, firstSeries: "7.0"},
+			{name: "amazon2", minOSVersion: 2, firstSeries: "4.4"},
+		},
+	},
+	"suse": {
+		name: "suse",
+		builds: []linuxBuild{
+			{name: "suse15", minOSVersion: 15, firstSeries: "4.4", arch: "x86_64"},
+			{name: "suse12", minOSVersion: 12, firstSeries: "4.4", lastSeries: "6.0", arch: "x86_64"},
+		},
+	},
+}
+
+// linuxCodenames maps the codenames of the Ubuntu and Debian versions to their major version numbers,
+// for the derivatives giving them in os-release
+var linuxCodenames = map[string]int{
+	"noble":    24,
+	"jammy":    22,
+	"focal":    20,
+	"bionic":   18,
+	"xenial":   16,
+	"bookworm": 12,
+	"bullseye": 11,
+	"buster":   10,
+	"stretch":  9,
+}
@@ -0,0 +1,63 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// lockPollInterval is the interval between attempts to acquire a cache lock held by another process.
+// We define it as a package var so we can override it in tests
+var lockPollInterval = 100 * time.Millisecond
+
+// lockCacheEntry acquires an exclusive advisory lock on the cache entry holding the given binary,
+// so that a single process downloads a given version at a time.
+// It waits for the lock to be released by other processes until the context is done.
+// The returned function releases the lock
+func lockCacheEntry(ctx context.Context, binPath string) (func(), error) {
+	lockPath := path.Dir(binPath) + ".lock"
+	if err := afs.MkdirAll(path.Dir(lockPath), 0755); err != nil {
+		log.Error(ctx, "error creating cache directory", err, log.Data{"dir": path.Dir(lockPath)})
+		return nil, err
+	}
+
+	lockFile, err := afs.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		log.Error(ctx, "error opening cache lock file", err, log.Data{"filename": lockPath})
+		return nil, err
+	}
+
+	waiting := false
+	for {
+		locked, lockErr := tryLock(lockFile)
+		if lockErr != nil {
+			log.Error(ctx, "error locking cache entry", lockErr, log.Data{"filename": lockPath})
+			_ = lockFile.Close()
+			return nil, lockErr
+		}
+		if locked {
+			break
+		}
+
+		if !waiting {
+			log.Info(ctx, "Waiting for another process to download MongoDB", log.Data{"filename": lockPath})
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			_ = lockFile.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		if err := unlock(lockFile); err != nil {
+			log.Error(ctx, "error unlocking cache entry", err, log.Data{"filename": lockPath})
+		}
+		_ = lockFile.Close()
+	}, nil
+}
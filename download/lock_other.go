@@ -0,0 +1,15 @@
+//go:build !unix
+
+package download
+
+import "github.com/spf13/afero"
+
+// tryLock is a no-op on systems without flock, which are not supported to run MongoDB anyway
+func tryLock(afero.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op on systems without flock
+func unlock(afero.File) error {
+	return nil
+}
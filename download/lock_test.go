@@ -0,0 +1,77 @@
+package download
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestLockCacheEntry(t *testing.T) {
+	testCtx := context.Background()
+	originalLockPollInterval := lockPollInterval
+
+	// Use the OS filesystem, as locks are not supported by the memory backed one
+	afs = afero.Afero{Fs: afero.NewOsFs()}
+
+	Convey("Given a cache entry locked by a downloader", t, func() {
+		lockPollInterval = 10 * time.Millisecond
+		binPath := path.Join(t.TempDir(), folderName, "mongodb-linux-x86_64-5.0.2.tgz", "mongod")
+
+		unlockEntry, err := lockCacheEntry(testCtx, binPath)
+		So(err, ShouldBeNil)
+
+		Convey("When another downloader tries to lock it before the context is done", func() {
+			ctx, cancel := context.WithTimeout(testCtx, 50*time.Millisecond)
+			defer cancel()
+			_, err := lockCacheEntry(ctx, binPath)
+
+			Convey("Then the context error is returned", func() {
+				So(err, ShouldEqual, context.DeadlineExceeded)
+			})
+			unlockEntry()
+		})
+
+		Convey("When the lock is released while another downloader waits for it", func() {
+			time.AfterFunc(50*time.Millisecond, unlockEntry)
+			unlockOther, err := lockCacheEntry(testCtx, binPath)
+
+			Convey("Then the other downloader gets the lock", func() {
+				So(err, ShouldBeNil)
+				unlockOther()
+			})
+		})
+
+		Convey("When the downloader stores the binaries in cache while GetMongoDB waits for the lock", func() {
+			cfg := Config{
+				artifact:  "should-not-be-called",
+				source:    NewDirSource(t.TempDir()),
+				cachePath: binPath,
+			}
+			done := make(chan struct{})
+			time.AfterFunc(50*time.Millisecond, func() {
+				defer close(done)
+				_ = afs.MkdirAll(path.Dir(binPath), 0755)
+				for _, p := range cfg.binPaths() {
+					_ = afs.WriteFile(p, []byte("test"), 0755)
+				}
+				unlockEntry()
+			})
+
+			err := GetMongoDB(testCtx, cfg)
+			// The downloader may still be writing the binaries not required by GetMongoDB
+			<-done
+
+			Convey("Then GetMongoDB uses them without downloading them again", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Reset(func() {
+			lockPollInterval = originalLockPollInterval
+		})
+	})
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package download
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// tryLock tries to acquire an exclusive flock on the file without blocking, and reports whether it did.
+// Files not backed by the OS (e.g. in memory files) are always locked
+func tryLock(file afero.File) (bool, error) {
+	f, ok := file.(interface{ Fd() uintptr })
+	if !ok {
+		return true, nil
+	}
+
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlock releases the flock acquired by tryLock
+func unlock(file afero.File) error {
+	f, ok := file.(interface{ Fd() uintptr })
+	if !ok {
+		return nil
+	}
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// manifestFileName is the name of the file holding the Manifest of a cache entry
+const manifestFileName = "manifest.json"
+
+// VerifyCacheEnv is the environment variable that enables the verification of the cached binaries
+// against their manifest before use, when set to a true value (e.g. "true" or "1")
+const VerifyCacheEnv = "MIM_VERIFY_CACHE"
+
+// ErrNoManifest is returned when verifying a cache entry that has no manifest,
+// e.g. because it was installed by an older version of this package
+var ErrNoManifest = errors.New("no manifest found for the cache entry")
+
+// Manifest records where the binaries of a cache entry come from and their checksums, as of their installation
+type Manifest struct {
+	// Source is the location the tarball was downloaded from
+	Source string `json:"source"`
+	// TarballSHA256 is the SHA-256 of the tarball, as verified against the published checksum
+	TarballSHA256 string `json:"tarballSha256"`
+	// KeyFingerprint is the fingerprint of the key the tarball signature was verified with
+	KeyFingerprint string `json:"keyFingerprint"`
+	// Binaries holds the SHA-256 of the binaries extracted from the tarball, keyed by name
+	Binaries map[string]string `json:"binaries"`
+	// InstalledAt is the time the binaries were installed in the cache
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// writeManifest stores the manifest in the given cache entry directory
+func writeManifest(dir string, manifest *Manifest) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afs.WriteFile(path.Join(dir, manifestFileName), b, 0644)
+}
+
+// readManifest returns the manifest of the given cache entry directory, or ErrNoManifest if there is none
+func readManifest(dir string) (*Manifest, error) {
+	b, err := afs.ReadFile(path.Join(dir, manifestFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoManifest
+		}
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err = json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if len(manifest.Binaries) == 0 {
+		return nil, errors.New("invalid manifest: no binaries recorded")
+	}
+	return manifest, nil
+}
+
+// verifyEntry checks the binaries of the given cache entry directory against its manifest
+func verifyEntry(dir string) error {
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	for name, expected := range manifest.Binaries {
+		actual, err := sha256Sum(path.Join(dir, name), io.Discard)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return &ChecksumMismatchError{Name: path.Join(dir, name), Expected: expected, Actual: actual}
+		}
+	}
+	return nil
+}
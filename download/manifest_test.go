@@ -0,0 +1,88 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestManifest(t *testing.T) {
+	pinTestKey(t)
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a source holding a valid tarball", t, func() {
+		mirror, _ := afs.TempDir("", "")
+		for from, to := range map[string]string{
+			"mongodb-test.tgz":        "linux/mongodb-test.tgz",
+			"mongodb-test.tgz.sha256": "linux/mongodb-test.tgz.sha256",
+			"mongodb-test.tgz.sig":    "linux/mongodb-test.tgz.sig",
+			"key-correct.asc":         "static/pgp/server-5.0.asc",
+		} {
+			So(copyToAfs("testdata/"+from, path.Join(mirror, to)), ShouldBeNil)
+		}
+
+		cacheDir, _ := afs.TempDir("", "")
+		cfg := Config{
+			mongoVersion: Version{Major: 5, Minor: 0, Patch: 2},
+			artifact:     "linux/mongodb-test.tgz",
+			source:       NewDirSource(mirror),
+			keySource:    NewDirSource(mirror),
+			cachePath:    path.Join(cacheDir, "mongod"),
+		}
+
+		Convey("When the binaries are installed", func() {
+			startTime := time.Now()
+			So(GetMongoDB(testCtx, cfg), ShouldBeNil)
+
+			Convey("Then a manifest describing them is written alongside", func() {
+				publishedChecksum, _ := os.ReadFile("testdata/mongodb-test.tgz.sha256")
+
+				manifest, err := readManifest(cacheDir)
+				So(err, ShouldBeNil)
+				So(manifest.Source, ShouldEqual, path.Join(mirror, "linux/mongodb-test.tgz"))
+				So(manifest.TarballSHA256, ShouldEqual, strings.Fields(string(publishedChecksum))[0])
+				So(manifest.KeyFingerprint, ShouldHaveLength, 40)
+				So(manifest.Binaries, ShouldHaveLength, 2)
+				So(manifest.InstalledAt, ShouldHappenBetween, startTime.Add(-time.Second), time.Now())
+				So(verifyEntry(cacheDir), ShouldBeNil)
+			})
+
+			Convey("And a cached binary is modified", func() {
+				So(afs.WriteFile(cfg.MongoPath(), []byte("tampered"), 0755), ShouldBeNil)
+
+				Convey("Then it is used as is if the verification of the cache is disabled", func() {
+					So(GetMongoDB(testCtx, cfg), ShouldBeNil)
+					content, _ := afs.ReadFile(cfg.MongoPath())
+					So(string(content), ShouldEqual, "tampered")
+				})
+
+				Convey("Then it is installed again if the verification of the cache is enabled", func() {
+					cfg.verifyCache = true
+					So(GetMongoDB(testCtx, cfg), ShouldBeNil)
+					content, _ := afs.ReadFile(cfg.MongoPath())
+					So(string(content), ShouldNotEqual, "tampered")
+					So(verifyEntry(cacheDir), ShouldBeNil)
+				})
+			})
+
+			Convey("And the manifest is missing", func() {
+				So(afs.Remove(path.Join(cacheDir, manifestFileName)), ShouldBeNil)
+
+				Convey("Then the binaries are installed again if the verification of the cache is enabled", func() {
+					cfg.verifyCache = true
+					So(GetMongoDB(testCtx, cfg), ShouldBeNil)
+					So(verifyEntry(cacheDir), ShouldBeNil)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// DefaultMongoshURL is the location of the mongosh archives, which are not published with the MongoDB server tarballs
+const DefaultMongoshURL = "https://downloads.mongodb.com"
+
+// DefaultMongoshVersion is the version of mongosh installed if WithTools is given "mongosh",
+// unless WithMongoshVersion says otherwise. mongosh 2 supports MongoDB 4.2 and above
+const DefaultMongoshVersion = "2.2.5"
+
+// mongoshKeySeries is the name the key mongosh releases are signed with is pinned for, in pinnedKeyFingerprints
+const mongoshKeySeries = "mongosh"
+
+// mongoshArtifactPath returns the path to the mongosh archive of the given version for the given platform
+// ("linux" or "osx") and architecture ("x86_64" or "arm64"), relative to the root of a download Source
+// The archives are zip files on MacOS, and tarballs on Linux
+func mongoshArtifactPath(v Version, platform, arch string) (string, error) {
+	if arch == "x86_64" {
+		arch = "x64"
+	}
+
+	switch platform {
+	case "linux":
+		return fmt.Sprintf("compass/mongosh-%s-linux-%s.tgz", v.String(), arch), nil
+	case "osx":
+		return fmt.Sprintf("compass/mongosh-%s-darwin-%s.zip", v.String(), arch), nil
+	default:
+		return "", &UnsupportedSystemError{msg: "platform " + platform + " not supported"}
+	}
+}
+
+// mongoshArtifact returns the path to the mongosh archive, relative to the mongosh source,
+// for the target platform if any or else the current one
+func (cfg *Config) mongoshArtifact() (string, error) {
+	version := cfg.mongoshVersion
+	if version == "" {
+		version = DefaultMongoshVersion
+	}
+	v, err := NewVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.target != nil {
+		arch, _, err := checkPlatform(cfg.target.platform, cfg.target.arch, cfg.target.osName)
+		if err != nil {
+			return "", err
+		}
+		return mongoshArtifactPath(*v, cfg.target.platform, arch)
+	}
+
+	arch, err := detectArch()
+	if err != nil {
+		return "", err
+	}
+	platform, err := detectPlatform()
+	if err != nil {
+		return "", err
+	}
+	return mongoshArtifactPath(*v, platform, arch)
+}
+
+// downloadMongosh downloads the mongosh archive, verifies its signature and extracts mongosh.
+// It returns the path to the extracted file, keyed by name as for extractMongoBins
+func downloadMongosh(ctx context.Context, cfg Config) (map[string]string, error) {
+	location := cfg.mongoshSource.Location(cfg.mongoshArtifactPath)
+
+	downloadedFile, err := downloadFile(ctx, cfg.mongoshSource, cfg.mongoshArtifactPath, cfg.progress)
+	if err != nil {
+		log.Error(ctx, "error downloading file", err, log.Data{"url": location})
+		return nil, err
+	}
+
+	defer func() {
+		_ = downloadedFile.Close()
+		_ = afs.Remove(downloadedFile.Name())
+	}()
+
+	key, err := cfg.signingKeyNamed(ctx, mongoshKeySeries, "mongosh")
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := checkSignature(ctx, cfg, cfg.mongoshSource, cfg.mongoshArtifactPath+".sig", key, downloadedFile.Name())
+	if err != nil {
+		log.Error(ctx, "error verifying integrity of mongosh package", err, log.Data{"url": location})
+		return nil, err
+	}
+	log.Info(ctx, "signature verified successfully", log.Data{"url": location + ".sig", "key": fingerprint})
+
+	names := []string{"mongosh"}
+	var tmpFiles map[string]string
+	if strings.HasSuffix(cfg.mongoshArtifactPath, ".zip") {
+		tmpFiles, err = extractZipBins(ctx, downloadedFile, names)
+	} else {
+		tmpFiles, err = extractMongoBins(ctx, downloadedFile, names, newProgressWriter(cfg.progress, PhaseExtracting, contentSize(downloadedFile)))
+	}
+	if err != nil {
+		return nil, &ExtractionError{URL: location, Err: err}
+	}
+	return tmpFiles, nil
+}
@@ -0,0 +1,139 @@
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// testMongoshArchive returns a tarball holding mongosh and its crypt library in the layout of the published archives
+func testMongoshArchive(t *testing.T, dir string) []byte {
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{"mongosh", "mongosh_crypt_v1.so"} {
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/bin/" + name, Mode: 0755, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(tw, name)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testSigningEntity returns a new key pair, and its public key armored
+func testSigningEntity(t *testing.T) (*openpgp.Entity, []byte) {
+	entity, err := openpgp.NewEntity("mongosh test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return entity, buf.Bytes()
+}
+
+// testSignature returns the armored detached signature of the content by the entity
+func testSignature(t *testing.T, entity *openpgp.Entity, content []byte) []byte {
+	buf := new(bytes.Buffer)
+	if err := openpgp.ArmoredDetachSign(buf, entity, bytes.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGetMongoDBWithMongosh(t *testing.T) {
+	testCtx := context.Background()
+	pinTestKey(t)
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	signer, publicKey := testSigningEntity(t)
+	other, _ := testSigningEntity(t)
+	archiveName := "compass/mongosh-2.2.5-linux-x64.tgz"
+	archive := testMongoshArchive(t, "mongosh-2.2.5-linux-x64")
+
+	Convey("Given a mirror holding a MongoDB tarball and a signed mongosh archive", t, func() {
+		pinnedKeyFingerprints["mongosh"] = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+		mirror, _ := afs.TempDir("", "")
+		for from, to := range map[string]string{
+			"mongodb-test.tgz":        "linux/mongodb-linux-x86_64-test-5.0.2.tgz",
+			"mongodb-test.tgz.sha256": "linux/mongodb-linux-x86_64-test-5.0.2.tgz.sha256",
+			"mongodb-test.tgz.sig":    "linux/mongodb-linux-x86_64-test-5.0.2.tgz.sig",
+			"key-correct.asc":         "static/pgp/server-5.0.asc",
+		} {
+			So(copyToAfs("testdata/"+from, path.Join(mirror, to)), ShouldBeNil)
+		}
+		So(afs.WriteFile(path.Join(mirror, archiveName), archive, 0644), ShouldBeNil)
+		So(afs.WriteFile(path.Join(mirror, archiveName+".sig"), testSignature(t, signer, archive), 0644), ShouldBeNil)
+		So(afs.WriteFile(path.Join(mirror, "static/pgp/mongosh.asc"), publicKey, 0644), ShouldBeNil)
+		cacheRoot, _ := afs.TempDir("", "")
+
+		prefetch := func() (*Config, error) {
+			return Prefetch(testCtx, "5.0.2", WithSource(NewDirSource(mirror)), WithPlatform("linux", "x86_64", "test"),
+				WithCacheRoot(cacheRoot), WithTools("mongosh"))
+		}
+
+		Convey("When the binaries are installed with mongosh", func() {
+			cfg, err := prefetch()
+
+			Convey("Then mongosh is stored in the bundle of the version and recorded in its manifest", func() {
+				So(err, ShouldBeNil)
+				content, err := afs.ReadFile(cfg.ToolPath("mongosh"))
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "mongosh")
+				exists, _ := afs.Exists(cfg.MongoPath())
+				So(exists, ShouldBeTrue)
+
+				manifest, err := readManifest(path.Dir(cfg.MongoPath()))
+				So(err, ShouldBeNil)
+				So(manifest.Binaries, ShouldContainKey, "mongosh")
+				So(verifyEntry(path.Dir(cfg.MongoPath())), ShouldBeNil)
+			})
+		})
+
+		Convey("When the mongosh archive is not signed with the pinned key", func() {
+			So(afs.WriteFile(path.Join(mirror, archiveName+".sig"), testSignature(t, other, archive), 0644), ShouldBeNil)
+			_, err := prefetch()
+
+			Convey("Then a SignatureError is returned", func() {
+				var sigErr *SignatureError
+				So(errors.As(err, &sigErr), ShouldBeTrue)
+				So(sigErr.URL, ShouldEqual, NewDirSource(mirror).Location(archiveName+".sig"))
+			})
+		})
+
+		Convey("When no key is pinned for mongosh", func() {
+			delete(pinnedKeyFingerprints, "mongosh")
+			_, err := prefetch()
+
+			Convey("Then an UnknownKeyError is returned unless trust on first use is enabled", func() {
+				So(err, ShouldResemble, &UnknownKeyError{Series: "mongosh"})
+			})
+		})
+	})
+}
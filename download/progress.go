@@ -0,0 +1,99 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Phase is a step of the installation of a MongoDB tarball
+type Phase string
+
+// Phases of the installation, in the order they happen
+const (
+	PhaseDownloading        Phase = "downloading"
+	PhaseVerifyingChecksum  Phase = "verifying checksum"
+	PhaseVerifyingSignature Phase = "verifying signature"
+	PhaseExtracting         Phase = "extracting"
+)
+
+// Progress reports how far the installation of a MongoDB tarball has gone
+type Progress struct {
+	// Phase is the current step of the installation
+	Phase Phase
+	// Bytes is the number of bytes of the tarball processed so far in the phase
+	Bytes int64
+	// Total is the size of the tarball in bytes, or -1 if unknown (e.g. no Content-Length was given)
+	Total int64
+}
+
+// ProgressFunc is called with the progress of the installation every time some bytes are processed
+type ProgressFunc func(Progress)
+
+// progressRenderInterval is the minimum interval between the progress lines rendered by default
+const progressRenderInterval = 200 * time.Millisecond
+
+// stderrIsTerminal reports whether the standard error is a terminal
+// We define it as a package var so we can override it in tests
+var stderrIsTerminal = func() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// NewProgressRenderer returns a ProgressFunc rendering the progress as a line on the given writer,
+// updated in place at most once per interval. A line is completed at the end of every phase
+func NewProgressRenderer(w io.Writer, interval time.Duration) ProgressFunc {
+	var lastRender time.Time
+	var lastPhase Phase
+	return func(p Progress) {
+		done := p.Total >= 0 && p.Bytes >= p.Total
+		if p.Phase == lastPhase && !done && time.Since(lastRender) < interval {
+			return
+		}
+		lastRender, lastPhase = time.Now(), p.Phase
+
+		line := fmt.Sprintf("\r\033[KMongoDB %s: %s", p.Phase, formatBytes(p.Bytes))
+		if p.Total >= 0 {
+			percent := int64(100)
+			if p.Total > 0 {
+				percent = p.Bytes * 100 / p.Total
+			}
+			line += fmt.Sprintf(" / %s (%d%%)", formatBytes(p.Total), percent)
+		}
+		if done {
+			line += "\n"
+			// Next update of the same phase (if any) starts a new line
+			lastPhase = ""
+		}
+		_, _ = io.WriteString(w, line)
+	}
+}
+
+// formatBytes returns the given number of bytes in MB
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// progressWriter is an io.Writer reporting the number of bytes written through it as the progress of a phase
+type progressWriter struct {
+	progress ProgressFunc
+	phase    Phase
+	bytes    int64
+	total    int64
+}
+
+// newProgressWriter returns a writer reporting the progress of the given phase to the given function,
+// or io.Discard if there is none
+func newProgressWriter(progress ProgressFunc, phase Phase, total int64) io.Writer {
+	if progress == nil {
+		return io.Discard
+	}
+	return &progressWriter{progress: progress, phase: phase, total: total}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.bytes += int64(len(p))
+	w.progress(Progress{Phase: w.phase, Bytes: w.bytes, Total: w.total})
+	return len(p), nil
+}
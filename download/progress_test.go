@@ -0,0 +1,98 @@
+package download
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestProgressRenderer(t *testing.T) {
+	Convey("Given a progress renderer", t, func() {
+		buf := new(strings.Builder)
+		render := NewProgressRenderer(buf, time.Hour)
+
+		Convey("When the progress of a phase is reported several times within the interval", func() {
+			render(Progress{Phase: PhaseDownloading, Bytes: 1 << 20, Total: 4 << 20})
+			render(Progress{Phase: PhaseDownloading, Bytes: 2 << 20, Total: 4 << 20})
+			render(Progress{Phase: PhaseDownloading, Bytes: 4 << 20, Total: 4 << 20})
+
+			Convey("Then only the first and the final progress are rendered, completing the line", func() {
+				So(buf.String(), ShouldEqual,
+					"\r\033[KMongoDB downloading: 1.0 MB / 4.0 MB (25%)"+
+						"\r\033[KMongoDB downloading: 4.0 MB / 4.0 MB (100%)\n")
+			})
+		})
+
+		Convey("When the total size is unknown", func() {
+			render(Progress{Phase: PhaseDownloading, Bytes: 1 << 19, Total: -1})
+
+			Convey("Then only the bytes processed are rendered", func() {
+				So(buf.String(), ShouldEqual, "\r\033[KMongoDB downloading: 0.5 MB")
+			})
+		})
+
+		Convey("When a new phase starts", func() {
+			render(Progress{Phase: PhaseDownloading, Bytes: 1 << 20, Total: -1})
+			render(Progress{Phase: PhaseExtracting, Bytes: 1 << 20, Total: -1})
+
+			Convey("Then it is rendered straight away", func() {
+				So(buf.String(), ShouldEndWith, "\r\033[KMongoDB extracting: 1.0 MB")
+			})
+		})
+	})
+}
+
+func TestGetMongoDBProgress(t *testing.T) {
+	pinTestKey(t)
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a source holding a valid tarball and a progress function", t, func() {
+		mirror, _ := afs.TempDir("", "")
+		for from, to := range map[string]string{
+			"mongodb-test.tgz":        "linux/mongodb-test.tgz",
+			"mongodb-test.tgz.sha256": "linux/mongodb-test.tgz.sha256",
+			"mongodb-test.tgz.sig":    "linux/mongodb-test.tgz.sig",
+			"key-correct.asc":         "static/pgp/server-5.0.asc",
+		} {
+			So(copyToAfs("testdata/"+from, path.Join(mirror, to)), ShouldBeNil)
+		}
+		tarball, _ := afs.Stat(path.Join(mirror, "linux/mongodb-test.tgz"))
+
+		var phases []Phase
+		last := make(map[Phase]Progress)
+		cacheDir, _ := afs.TempDir("", "")
+		cfg := Config{
+			mongoVersion: Version{Major: 5, Minor: 0, Patch: 2},
+			artifact:     "linux/mongodb-test.tgz",
+			source:       NewDirSource(mirror),
+			keySource:    NewDirSource(mirror),
+			cachePath:    path.Join(cacheDir, "mongod"),
+			progress: func(p Progress) {
+				if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+					phases = append(phases, p.Phase)
+				}
+				last[p.Phase] = p
+			},
+		}
+
+		Convey("When GetMongoDB is called", func() {
+			err := GetMongoDB(testCtx, cfg)
+
+			Convey("Then every phase is reported in order, up to the size of the tarball", func() {
+				So(err, ShouldBeNil)
+				So(phases, ShouldResemble, []Phase{PhaseDownloading, PhaseVerifyingChecksum, PhaseVerifyingSignature, PhaseExtracting})
+				for _, phase := range phases {
+					So(last[phase], ShouldResemble, Progress{Phase: phase, Bytes: tarball.Size(), Total: tarball.Size()})
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,304 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// DefaultReleasesURL is the location of the MongoDB release manifest
+const DefaultReleasesURL = "https://downloads.mongodb.org"
+
+// releasesManifestName is the name of the MongoDB release manifest, in its source and in the cache
+const releasesManifestName = "full.json"
+
+// defaultReleasesTTL is how long the cached release manifest is used before being downloaded again
+const defaultReleasesTTL = 24 * time.Hour
+
+// Keywords resolving to the latest production release, and to the latest long term support release
+const (
+	VersionLatest    = "latest"
+	VersionLatestLTS = "latest-lts"
+)
+
+var (
+	partialVersionRegexp    = regexp.MustCompile(`^\d+(\.\d+)?$`)
+	versionConstraintRegexp = regexp.MustCompile(`^(>=|<=|>|<|=)(\d+(?:\.\d+){0,2})$`)
+)
+
+// releasesManifest is the part of the MongoDB release manifest (full.json) we use
+type releasesManifest struct {
+	Versions []release `json:"versions"`
+}
+
+// release describes a MongoDB release in the release manifest
+type release struct {
+	Version           string `json:"version"`
+	ProductionRelease bool   `json:"production_release"`
+	Downloads         []struct {
+		Archive struct {
+			URL string `json:"url"`
+		} `json:"archive"`
+	} `json:"downloads"`
+}
+
+// hasArtifact reports whether the release includes the given tarball
+func (r *release) hasArtifact(name string) bool {
+	for _, d := range r.Downloads {
+		if path.Base(d.Archive.URL) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveVersion returns the concrete MongoDB version for the given version specification, with 0 or more
+// options as defined for NewConfig. The specification may be:
+// - a concrete version, e.g. 7.0.2, which is returned as is
+// - a partial version, e.g. 7.0 or 7, resolved to the latest matching release
+// - "latest", resolved to the latest release
+// - "latest-lts", resolved to the latest long term support release (x.0 since 5.0, even minor versions before)
+// - a range made of space separated constraints, e.g. ">=6.0 <7.0", resolved to the latest matching release
+//
+// Only production releases available for the current platform are considered. They are looked up in the MongoDB
+// release manifest, which is cached and downloaded again once a day. If it can not be downloaded
+// (e.g. in offline mode) the cached one is used whatever its age
+func ResolveVersion(ctx context.Context, spec string, opts ...ConfigOption) (*Version, error) {
+	cfg, err := newBaseConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.resolveVersion(ctx, spec)
+}
+
+// resolveVersion returns the concrete MongoDB version for the given version specification
+func (cfg *Config) resolveVersion(ctx context.Context, spec string) (*Version, error) {
+	matches, isSpec := parseVersionSpec(spec)
+	if !isSpec {
+		return NewVersion(spec)
+	}
+
+	manifest, err := cfg.releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []Version
+	for _, r := range manifest.Versions {
+		if !r.ProductionRelease {
+			continue
+		}
+		v, err := NewVersion(r.Version)
+		if err != nil || !matches(*v) {
+			continue
+		}
+		artifact, err := cfg.artifactPath(*v)
+		if err != nil || (len(r.Downloads) > 0 && !r.hasArtifact(path.Base(artifact))) {
+			continue
+		}
+		candidates = append(candidates, *v)
+	}
+	if len(candidates) == 0 {
+		return nil, &UnsupportedMongoVersionError{
+			version: spec,
+			msg:     "no release available for this platform matches the version",
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Compare(candidates[j]) > 0
+	})
+	log.Info(ctx, "MongoDB version resolved", log.Data{"spec": spec, "version": candidates[0].String()})
+	return &candidates[0], nil
+}
+
+// parseVersionSpec returns the function matching the versions of the given specification,
+// and whether it is a specification to be resolved rather than a concrete version
+func parseVersionSpec(spec string) (func(Version) bool, bool) {
+	spec = strings.TrimSpace(spec)
+
+	switch {
+	case spec == VersionLatest:
+		return func(Version) bool { return true }, true
+	case spec == VersionLatestLTS:
+		return isLTS, true
+	case partialVersionRegexp.MatchString(spec):
+		bound, parts := parseBound(spec)
+		return func(v Version) bool {
+			return v.Major == bound.Major && (parts < 2 || v.Minor == bound.Minor)
+		}, true
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	var constraints []func(Version) bool
+	for _, f := range fields {
+		match := versionConstraintRegexp.FindStringSubmatch(f)
+		if match == nil {
+			return nil, false
+		}
+		constraints = append(constraints, versionConstraint(match[1], match[2]))
+	}
+	return func(v Version) bool {
+		for _, c := range constraints {
+			if !c(v) {
+				return false
+			}
+		}
+		return true
+	}, true
+}
+
+// versionConstraint returns the function matching the versions satisfying the constraint given by
+// the operator and the bound. Missing parts of the bound are 0, e.g. >=6 is >=6.0.0
+func versionConstraint(op, boundStr string) func(Version) bool {
+	bound, _ := parseBound(boundStr)
+	return func(v Version) bool {
+		c := v.Compare(bound)
+		switch op {
+		case ">=":
+			return c >= 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case "<":
+			return c < 0
+		default:
+			return c == 0
+		}
+	}
+}
+
+// parseBound parses a version made of 1 to 3 numeric parts, the missing parts being 0.
+// It returns the version and the number of parts found
+func parseBound(s string) (Version, int) {
+	parts := strings.Split(s, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	v, _ := NewVersion(strings.Join(parts, "."))
+	return *v, len(strings.Split(s, "."))
+}
+
+// isLTS reports whether the version is a long term support release:
+// the x.0 releases since 5.0, and the releases with an even minor version before
+func isLTS(v Version) bool {
+	if v.Major >= 5 {
+		return v.Minor == 0
+	}
+	return v.Minor%2 == 0
+}
+
+// releases returns the MongoDB release manifest, from the cache if it is recent enough or
+// if it can not be downloaded
+func (cfg *Config) releases(ctx context.Context) (*releasesManifest, error) {
+	var manifest *releasesManifest
+	err := cfg.loadManifest(ctx, "MongoDB release manifest", releasesManifestName, releasesManifestName, func(filename string) (err error) {
+		manifest, err = readReleases(filename)
+		return err
+	})
+	return manifest, err
+}
+
+// loadManifest reads a manifest (described by what) with the given parse function, from the cache if it is recent
+// enough or if it can not be downloaded, and otherwise from the releases source where it is found by the given name
+func (cfg *Config) loadManifest(ctx context.Context, what, name, cacheName string, parse func(filename string) error) error {
+	cacheDir, err := cfg.cacheDir()
+	if err != nil {
+		return err
+	}
+	cachedPath := path.Join(cacheDir, cacheName)
+
+	info, statErr := afs.Stat(cachedPath)
+	cached := statErr == nil
+	if cached && (cfg.offline || time.Since(info.ModTime()) < cfg.releasesTTL) {
+		return parse(cachedPath)
+	}
+
+	fetchErr := cfg.fetchManifest(ctx, name, cachedPath, parse)
+	if fetchErr == nil {
+		return nil
+	}
+	if !cached {
+		if cfg.offline {
+			return errors.New("the " + what + " is not cached and offline mode is enabled")
+		}
+		return fetchErr
+	}
+
+	log.Warn(ctx, "Could not download the "+what+", using the cached one", log.Data{"filename": cachedPath, "error": fetchErr.Error()})
+	return parse(cachedPath)
+}
+
+// fetchManifest downloads the named manifest from the releases source, parses it and stores it in the cache
+func (cfg *Config) fetchManifest(ctx context.Context, name, cachedPath string, parse func(filename string) error) error {
+	if cfg.offline {
+		return errors.New("offline mode is enabled")
+	}
+
+	file, err := downloadFile(ctx, cfg.releasesSource, name, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = afs.Remove(file.Name())
+	}()
+
+	if err = parse(file.Name()); err != nil {
+		return err
+	}
+
+	content, err := afs.ReadFile(file.Name())
+	if err != nil {
+		return err
+	}
+	if err = afs.MkdirAll(path.Dir(cachedPath), 0755); err != nil {
+		return err
+	}
+	// Written to a temporary file first, so that concurrent readers never see a partial manifest
+	tmp, err := afs.TempFile(path.Dir(cachedPath), path.Base(cachedPath))
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = afs.Rename(tmp.Name(), cachedPath)
+	}
+	if err != nil {
+		_ = afs.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// readReleases parses the MongoDB release manifest stored in the given file
+func readReleases(filename string) (*releasesManifest, error) {
+	content, err := afs.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &releasesManifest{}
+	if err = json.Unmarshal(content, manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.Versions) == 0 {
+		return nil, errors.New("no versions found in the MongoDB release manifest")
+	}
+	return manifest, nil
+}
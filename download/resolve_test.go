@@ -0,0 +1,178 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// testReleases is a release manifest in the format of full.json.
+// 7.1.0 is only available for another platform, and 7.2.0-rc0 is not a production release
+const testReleases = `{"versions": [
+	{"version": "7.2.0-rc0", "production_release": false, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-7.2.0-rc0.tgz"}}]},
+	{"version": "7.1.0", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-other-7.1.0.tgz"}}]},
+	{"version": "7.0.4", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-7.0.4.tgz"}}]},
+	{"version": "7.0.2", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-7.0.2.tgz"}}]},
+	{"version": "6.3.1", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-6.3.1.tgz"}}]},
+	{"version": "6.0.12", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-6.0.12.tgz"}}]},
+	{"version": "6.0.9", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-6.0.9.tgz"}}]},
+	{"version": "4.4.25", "production_release": true, "downloads": [{"archive": {"url": "https://example.com/mongodb-test-4.4.25.tgz"}}]}
+]}`
+
+func TestResolveVersion(t *testing.T) {
+	var originalGetArtifactPath = getArtifactPath
+	var originalGetEnv = getEnv
+	var originalStderrIsTerminal = stderrIsTerminal
+	var originalRetryBaseDelay = retryBaseDelay
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a server publishing the release manifest", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.URL.Path != "/full.json" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, testReleases)
+		}))
+		cacheHome, _ := afs.TempDir("", "")
+		getEnv = func(key string) string {
+			if key == "XDG_CACHE_HOME" {
+				return cacheHome
+			}
+			return ""
+		}
+		getArtifactPath = func(v Version) (string, error) {
+			return "linux/mongodb-test-" + v.String() + ".tgz", nil
+		}
+		stderrIsTerminal = func() bool { return false }
+		retryBaseDelay = time.Millisecond
+		src := WithReleasesSource(NewHTTPSource(ts.URL, nil))
+
+		Reset(func() {
+			ts.Close()
+			getArtifactPath = originalGetArtifactPath
+			getEnv = originalGetEnv
+			stderrIsTerminal = originalStderrIsTerminal
+			retryBaseDelay = originalRetryBaseDelay
+		})
+
+		for spec, expected := range map[string]string{
+			"6.0.9":        "6.0.9",
+			"7.0":          "7.0.4",
+			"7":            "7.0.4",
+			"6":            "6.3.1",
+			"latest":       "7.0.4",
+			"latest-lts":   "7.0.4",
+			">=6.0 <7.0":   "6.3.1",
+			">=6.0 <6.1":   "6.0.12",
+			"<6":           "4.4.25",
+			"=6.0.9":       "6.0.9",
+			" >6.0.9 <=7 ": "6.3.1",
+		} {
+			Convey(fmt.Sprintf("When the version %q is resolved", spec), func() {
+				version, err := ResolveVersion(testCtx, spec, src)
+
+				Convey("Then the latest matching production release available for the platform is returned", func() {
+					So(err, ShouldBeNil)
+					So(version.String(), ShouldEqual, expected)
+				})
+			})
+		}
+
+		Convey("When no release matches the version", func() {
+			_, err := ResolveVersion(testCtx, ">=8.0", src)
+
+			Convey("Then an UnsupportedMongoVersionError is returned", func() {
+				So(err, ShouldHaveSameTypeAs, &UnsupportedMongoVersionError{})
+			})
+		})
+
+		Convey("When a version is resolved twice", func() {
+			_, err := ResolveVersion(testCtx, "latest", src)
+			So(err, ShouldBeNil)
+			_, err = ResolveVersion(testCtx, "7.0", src)
+			So(err, ShouldBeNil)
+
+			Convey("Then the manifest is downloaded once and cached", func() {
+				So(requests, ShouldEqual, 1)
+				exists, _ := afs.Exists(path.Join(cacheHome, folderName, releasesManifestName))
+				So(exists, ShouldBeTrue)
+			})
+
+			Convey("And the cached manifest is expired", func() {
+				_, err = ResolveVersion(testCtx, "latest", src, WithReleasesTTL(0))
+
+				Convey("Then the manifest is downloaded again", func() {
+					So(err, ShouldBeNil)
+					So(requests, ShouldEqual, 2)
+				})
+			})
+
+			Convey("And the cached manifest is expired and the server is unavailable", func() {
+				ts.Close()
+				version, err := ResolveVersion(testCtx, "latest", src, WithReleasesTTL(0))
+
+				Convey("Then the cached manifest is used", func() {
+					So(err, ShouldBeNil)
+					So(version.String(), ShouldEqual, "7.0.4")
+				})
+			})
+
+			Convey("And offline mode is enabled with an expired cached manifest", func() {
+				cachedPath := path.Join(cacheHome, folderName, releasesManifestName)
+				old := time.Now().Add(-48 * time.Hour)
+				So(afs.Chtimes(cachedPath, old, old), ShouldBeNil)
+				version, err := ResolveVersion(testCtx, "latest", src, WithOffline(true))
+
+				Convey("Then the cached manifest is used without downloading it", func() {
+					So(err, ShouldBeNil)
+					So(version.String(), ShouldEqual, "7.0.4")
+					So(requests, ShouldEqual, 1)
+				})
+			})
+		})
+
+		Convey("When a version is resolved in offline mode without a cached manifest", func() {
+			_, err := ResolveVersion(testCtx, "latest", src, WithOffline(true))
+
+			Convey("Then an error is returned without downloading the manifest", func() {
+				So(err, ShouldBeError)
+				So(requests, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When NewConfig is given a version to resolve", func() {
+			cfg, err := NewConfig(testCtx, "6.0", src)
+
+			Convey("Then the config is for the resolved version", func() {
+				So(err, ShouldBeNil)
+				So(cfg.Version(), ShouldResemble, Version{Major: 6, Minor: 0, Patch: 12})
+				So(cfg.artifact, ShouldEqual, "linux/mongodb-test-6.0.12.tgz")
+			})
+		})
+	})
+}
+
+func TestIsLTS(t *testing.T) {
+	Convey("Given MongoDB versions", t, func() {
+		Convey("Then the long term support releases are identified", func() {
+			So(isLTS(Version{Major: 7, Minor: 0}), ShouldBeTrue)
+			So(isLTS(Version{Major: 7, Minor: 1}), ShouldBeFalse)
+			So(isLTS(Version{Major: 5, Minor: 0}), ShouldBeTrue)
+			So(isLTS(Version{Major: 4, Minor: 4}), ShouldBeTrue)
+			So(isLTS(Version{Major: 4, Minor: 3}), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default locations of the MongoDB artifacts and of the MongoDB public keys
+const (
+	DefaultDownloadURL = "https://fastdl.mongodb.org"
+	DefaultKeyURL      = "https://www.mongodb.org"
+)
+
+// MirrorEnv is the environment variable giving the location of a mirror of the MongoDB artifacts.
+// Its value is given to NewSource, so it may be an http(s):// base URL, a file:// URL or a local directory
+const MirrorEnv = "MIM_DOWNLOAD_MIRROR"
+
+// defaultHTTPClient is the client used by the HTTP sources if none is given.
+// It honours the proxy environment variables and bounds the time to wait for the server to respond;
+// the time to download the whole artifact is bounded by the context only
+var defaultHTTPClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	return &http.Client{Transport: transport}
+}()
+
+// Source provides the artifacts needed to install MongoDB: the tarballs along with their checksum
+// and signature files, and the public keys used to sign them.
+// Artifacts are identified by their path relative to the root of the source, using the same layout as
+// the MongoDB download sites, e.g. "linux/mongodb-linux-x86_64-ubuntu2004-5.0.2.tgz" or "static/pgp/server-5.0.asc"
+// To report the progress of a download against the size of the artifact, the readers returned should implement
+// either Size() int64 or Stat() (os.FileInfo, error)
+type Source interface {
+	// Open returns a reader for the content of the named artifact
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Location returns where the named artifact is found, for logging purposes
+	Location(name string) string
+}
+
+// RangeSource is a Source able to open an artifact from a given offset, so that interrupted downloads can be resumed
+type RangeSource interface {
+	Source
+	// OpenRange returns a reader for the content of the named artifact starting at the given offset,
+	// along with the offset the content actually starts at, which is 0 if the source ignored the requested range
+	OpenRange(ctx context.Context, name string, offset int64) (io.ReadCloser, int64, error)
+}
+
+// NewSource returns the Source for the given location:
+// an HTTP source for http:// and https:// URLs, using the given client if not nil,
+// or a directory source for file:// URLs and local paths
+func NewSource(location string, client *http.Client) (Source, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPSource(location, client), nil
+	case "file":
+		return NewDirSource(u.Path), nil
+	case "":
+		return NewDirSource(location), nil
+	default:
+		return nil, fmt.Errorf("unsupported download source scheme %q", u.Scheme)
+	}
+}
+
+// NewHTTPSource returns a Source downloading the artifacts from the given base URL with the given client.
+// If the client is nil, a default client honouring the proxy environment variables is used.
+// The source supports resuming downloads through HTTP Range requests
+func NewHTTPSource(baseURL string, client *http.Client) RangeSource {
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	return &httpSource{baseURL: strings.TrimSuffix(baseURL, "/"), client: client}
+}
+
+// NewDirSource returns a Source reading the artifacts from the given local directory
+func NewDirSource(dir string) Source {
+	return &dirSource{dir: dir}
+}
+
+type httpSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (src *httpSource) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	body, _, err := src.OpenRange(ctx, name, 0)
+	return body, err
+}
+
+func (src *httpSource) OpenRange(ctx context.Context, name string, offset int64) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.Location(name), http.NoBody)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return &sizedBody{ReadCloser: resp.Body, size: resp.ContentLength}, 0, nil
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		return &sizedBody{ReadCloser: resp.Body, size: resp.ContentLength}, offset, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		_ = resp.Body.Close()
+		return nil, 0, &ArtifactNotFoundError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	default:
+		_ = resp.Body.Close()
+		return nil, 0, &HTTPStatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+}
+
+func (src *httpSource) Location(name string) string {
+	return src.baseURL + "/" + path.Clean("/" + name)[1:]
+}
+
+type dirSource struct {
+	dir string
+}
+
+func (src *dirSource) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	file, err := afs.Open(src.Location(name))
+	if os.IsNotExist(err) {
+		return nil, &ArtifactNotFoundError{URL: src.Location(name)}
+	}
+	return file, err
+}
+
+func (src *dirSource) Location(name string) string {
+	return filepath.Join(src.dir, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// sizedBody is the body of an HTTP response along with its size as given by its Content-Length, or -1 if unknown
+type sizedBody struct {
+	io.ReadCloser
+	size int64
+}
+
+func (b *sizedBody) Size() int64 {
+	return b.size
+}
+
+// contentSize returns the size of the content of an opened artifact, or -1 if unknown
+func contentSize(body io.Reader) int64 {
+	switch b := body.(type) {
+	case interface{ Size() int64 }:
+		return b.Size()
+	case interface{ Stat() (os.FileInfo, error) }:
+		if info, err := b.Stat(); err == nil {
+			return info.Size()
+		}
+	}
+	return -1
+}
@@ -0,0 +1,145 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+func TestNewSource(t *testing.T) {
+	Convey("Given an http(s) location", t, func() {
+		src, err := NewSource("https://mirror.example.com/mongodb/", nil)
+
+		Convey("Then an HTTP source is returned", func() {
+			So(err, ShouldBeNil)
+			So(src, ShouldHaveSameTypeAs, &httpSource{})
+			So(src.Location("linux/mongodb.tgz"), ShouldEqual, "https://mirror.example.com/mongodb/linux/mongodb.tgz")
+		})
+	})
+
+	Convey("Given a file:// location", t, func() {
+		src, err := NewSource("file:///srv/mirror", nil)
+
+		Convey("Then a directory source is returned", func() {
+			So(err, ShouldBeNil)
+			So(src, ShouldHaveSameTypeAs, &dirSource{})
+			So(src.Location("linux/mongodb.tgz"), ShouldEqual, "/srv/mirror/linux/mongodb.tgz")
+		})
+	})
+
+	Convey("Given a local directory", t, func() {
+		src, err := NewSource("/srv/mirror", nil)
+
+		Convey("Then a directory source is returned which does not escape the directory", func() {
+			So(err, ShouldBeNil)
+			So(src, ShouldHaveSameTypeAs, &dirSource{})
+			So(src.Location("../../etc/passwd"), ShouldEqual, "/srv/mirror/etc/passwd")
+		})
+	})
+
+	Convey("Given a location with an unsupported scheme", t, func() {
+		_, err := NewSource("ftp://mirror.example.com", nil)
+
+		Convey("Then an error is returned", func() {
+			So(err, ShouldBeError)
+		})
+	})
+}
+
+func TestGetMongoDBFromDirSource(t *testing.T) {
+	pinTestKey(t)
+	testCtx := context.Background()
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a local mirror holding a tarball, its checksum and signature, and the public key", t, func() {
+		mirror, _ := afs.TempDir("", "")
+		for from, to := range map[string]string{
+			"mongodb-test.tgz":        "linux/mongodb-test.tgz",
+			"mongodb-test.tgz.sha256": "linux/mongodb-test.tgz.sha256",
+			"mongodb-test.tgz.sig":    "linux/mongodb-test.tgz.sig",
+			"key-correct.asc":         "static/pgp/server-5.0.asc",
+		} {
+			So(copyToAfs("testdata/"+from, path.Join(mirror, to)), ShouldBeNil)
+		}
+
+		cacheDir, _ := afs.TempDir("", "")
+		cfg := Config{
+			mongoVersion: Version{Major: 5, Minor: 0, Patch: 2},
+			artifact:     "linux/mongodb-test.tgz",
+			source:       NewDirSource(mirror),
+			keySource:    NewDirSource(mirror),
+			cachePath:    path.Join(cacheDir, "mongod"),
+		}
+
+		Convey("When GetMongoDB is called", func() {
+			err := GetMongoDB(testCtx, cfg)
+
+			Convey("Then the binaries are verified and stored in the cache", func() {
+				So(err, ShouldBeNil)
+				for _, binPath := range cfg.binPaths() {
+					exists, _ := afs.Exists(binPath)
+					So(exists, ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("When the binaries for another platform are prefetched into a given cache root", func() {
+			So(copyToAfs("testdata/mongodb-test.tgz", path.Join(mirror, "linux/mongodb-linux-aarch64-test-5.0.2.tgz")), ShouldBeNil)
+			So(copyToAfs("testdata/mongodb-test.tgz.sha256", path.Join(mirror, "linux/mongodb-linux-aarch64-test-5.0.2.tgz.sha256")), ShouldBeNil)
+			So(copyToAfs("testdata/mongodb-test.tgz.sig", path.Join(mirror, "linux/mongodb-linux-aarch64-test-5.0.2.tgz.sig")), ShouldBeNil)
+			cacheRoot, _ := afs.TempDir("", "")
+
+			prefetched, err := Prefetch(testCtx, "5.0.2",
+				WithSource(NewDirSource(mirror)), WithPlatform("linux", "arm64", "test"), WithCacheRoot(cacheRoot))
+
+			Convey("Then the binaries are stored in the cache root without being run", func() {
+				So(err, ShouldBeNil)
+				So(prefetched.MongoPath(), ShouldEqual, path.Join(cacheRoot, "mongodb-linux-aarch64-test-5.0.2.tgz", "mongod"))
+				for _, binPath := range prefetched.binPaths() {
+					exists, _ := afs.Exists(binPath)
+					So(exists, ShouldBeTrue)
+				}
+			})
+		})
+
+		Convey("When the artifact is not in the mirror", func() {
+			cfg.artifact = "linux/missing.tgz"
+			err := GetMongoDB(testCtx, cfg)
+
+			Convey("Then an ArtifactNotFoundError is returned", func() {
+				So(err, ShouldResemble, &ArtifactNotFoundError{URL: path.Join(mirror, "linux/missing.tgz")})
+				So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+// copyToAfs copies a file from the OS filesystem to the package filesystem
+func copyToAfs(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = afs.MkdirAll(path.Dir(to), 0755); err != nil {
+		return err
+	}
+	dst, err := afs.Create(to)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	return err
+}
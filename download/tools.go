@@ -0,0 +1,408 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// toolsReleasesManifestName is the name of the MongoDB Database Tools release manifest, in its source
+const toolsReleasesManifestName = "tools/db/release.json"
+
+// toolsReleasesCacheName is the name of the MongoDB Database Tools release manifest in the cache
+const toolsReleasesCacheName = "tools-release.json"
+
+// databaseToolsKeySeries is the name the key the MongoDB Database Tools releases are signed with is pinned for,
+// in pinnedKeyFingerprints
+const databaseToolsKeySeries = "database-tools"
+
+// databaseTools lists the executables extracted from the MongoDB Database Tools archive into the cache
+var databaseTools = []string{"bsondump", "mongodump", "mongoexport", "mongofiles", "mongoimport", "mongorestore", "mongostat", "mongotop"}
+
+// toolsReleasesManifest is the part of the MongoDB Database Tools release manifest (release.json) we use
+type toolsReleasesManifest struct {
+	Versions []toolsRelease `json:"versions"`
+}
+
+// toolsRelease describes a MongoDB Database Tools release in the release manifest
+type toolsRelease struct {
+	Version   string `json:"version"`
+	Downloads []struct {
+		Archive struct {
+			URL    string `json:"url"`
+			SHA256 string `json:"sha256"`
+		} `json:"archive"`
+	} `json:"downloads"`
+}
+
+// archiveChecksum returns the published SHA-256 of the given archive, and whether the release includes it
+func (r *toolsRelease) archiveChecksum(name string) (string, bool) {
+	for _, d := range r.Downloads {
+		if path.Base(d.Archive.URL) == name {
+			return d.Archive.SHA256, true
+		}
+	}
+	return "", false
+}
+
+// ToolsSpec specifies what copy of the MongoDB Database Tools to download
+type ToolsSpec struct {
+	// Version is what version of the MongoDB Database Tools to download, e.g. 100.9.4
+	version *Version
+
+	// Platform is "osx" or "linux"
+	Platform string
+
+	// Arch
+	Arch string
+
+	// OSName is the name of the build for the Linux distribution, e.g. ubuntu2204 or rhel80,
+	// or "" for MacOS
+	OSName string
+}
+
+// MakeToolsSpec returns a ToolsSpec for the current operating system.
+// On Linux, the build is the newest MongoDB build for the distribution version
+func MakeToolsSpec(version Version) (*ToolsSpec, error) {
+	arch, archErr := detectArch()
+	if archErr != nil {
+		return nil, archErr
+	}
+
+	platform, platformErr := detectPlatform()
+	if platformErr != nil {
+		return nil, platformErr
+	}
+
+	osName, osErr := detectToolsLinuxId(arch)
+	if osErr != nil {
+		return nil, osErr
+	}
+
+	return &ToolsSpec{
+		version:  &version,
+		Arch:     arch,
+		Platform: platform,
+		OSName:   osName,
+	}, nil
+}
+
+// NewToolsSpec returns a ToolsSpec for the given platform ("linux" or "osx"), architecture ("x86_64" or "arm64",
+// also given as "aarch64") and, on Linux, name of the build for the distribution (e.g. "ubuntu2204" or "rhel80"), whatever the current system
+func NewToolsSpec(version Version, platform, arch, osName string) (*ToolsSpec, error) {
+	arch, osName, err := checkPlatform(platform, arch, osName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ToolsSpec{
+		version:  &version,
+		Arch:     arch,
+		Platform: platform,
+		OSName:   osName,
+	}, nil
+}
+
+// GetArtifactPath returns the path to the tools archive, relative to the root of a download Source.
+// The archives are zip files on MacOS, and tarballs on Linux
+func (spec *ToolsSpec) GetArtifactPath() (string, error) {
+	paths, err := spec.artifactPaths()
+	if err != nil {
+		return "", err
+	}
+	return paths[0], nil
+}
+
+// artifactPaths returns the paths the tools archive may have, most likely first. The Linux archives for ARM
+// are named after arm64 for some builds (e.g. ubuntu2204-arm64) and after aarch64 for others (e.g. rhel82-aarch64)
+func (spec *ToolsSpec) artifactPaths() ([]string, error) {
+	archiveName := "mongodb-database-tools-"
+
+	switch spec.Platform {
+	case "linux":
+		if spec.OSName == "" {
+			return nil, fmt.Errorf("invalid spec: OS name not provided")
+		}
+		paths := []string{fmt.Sprintf("tools/db/%s%s-%s-%s.tgz", archiveName, spec.OSName, spec.Arch, spec.Version())}
+		if spec.Arch == "arm64" {
+			paths = append(paths, fmt.Sprintf("tools/db/%s%s-%s-%s.tgz", archiveName, spec.OSName, linuxArch(spec.Arch), spec.Version()))
+		}
+		return paths, nil
+	case "osx":
+		return []string{fmt.Sprintf("tools/db/%smacos-%s-%s.zip", archiveName, spec.Arch, spec.Version())}, nil
+	default:
+		return nil, fmt.Errorf("invalid spec: unsupported platform %s", spec.Platform)
+	}
+}
+
+// Version returns the MongoDB Database Tools version
+func (spec *ToolsSpec) Version() string {
+	return spec.version.String()
+}
+
+// ToolsConfig keeps the configuration values for downloading and storing the MongoDB Database Tools
+type ToolsConfig struct {
+	// The configuration values that do not depend on the version: sources, cache root, offline mode...
+	cfg *Config
+	// The MongoDB Database Tools version we are using
+	version Version
+	// The path to the tools archive, relative to the source
+	artifact string
+	// The SHA-256 of the tools archive, as published in the release manifest
+	checksum string
+	// The directory where the tools can be found if previously downloaded
+	cacheDir string
+}
+
+// NewToolsConfig creates the config values for the given version of the MongoDB Database Tools, with 0 or more
+// options as defined for NewConfig (WithTools excepted).
+// The version may be concrete (e.g. 100.9.4), partial (e.g. 100.9), "latest" or a range (e.g. ">=100.8 <100.10"),
+// resolved to the latest matching release available for the platform.
+// The releases are looked up in the MongoDB Database Tools release manifest, downloaded from the releases source
+// and cached as the MongoDB release manifest is. The archive of the release is identified for the current system,
+// or the one given by WithPlatform, and its tools are cached under the same cache root as the MongoDB binaries
+func NewToolsConfig(ctx context.Context, version string, opts ...ConfigOption) (*ToolsConfig, error) {
+	cfg, err := newBaseConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, isSpec := parseVersionSpec(version)
+	if !isSpec {
+		concrete, err := NewVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		matches = func(v Version) bool { return v.Compare(*concrete) == 0 }
+	}
+
+	manifest, err := cfg.toolsReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []ToolsConfig
+	for _, r := range manifest.Versions {
+		v, err := NewVersion(r.Version)
+		if err != nil || !matches(*v) {
+			continue
+		}
+		artifacts, err := cfg.toolsArtifactPaths(*v)
+		if err != nil {
+			return nil, err
+		}
+		for _, artifact := range artifacts {
+			if checksum, ok := r.archiveChecksum(path.Base(artifact)); ok {
+				candidates = append(candidates, ToolsConfig{cfg: cfg, version: *v, artifact: artifact, checksum: checksum})
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, &UnsupportedMongoVersionError{
+			version: version,
+			msg:     "no release of the MongoDB Database Tools available for this platform matches the version",
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].version.Compare(candidates[j].version) > 0
+	})
+	tools := &candidates[0]
+
+	cacheDir, err := cfg.cacheDir()
+	if err != nil {
+		log.Error(ctx, "cache directory not found", err)
+		return nil, err
+	}
+	tools.cacheDir = path.Join(cacheDir, path.Base(tools.artifact))
+
+	log.Info(ctx, "MongoDB Database Tools version resolved", log.Data{"spec": version, "version": tools.version.String()})
+	return tools, nil
+}
+
+// toolsArtifactPaths returns the paths the tools archive for a given version may have, relative to the download source,
+// for the target platform if any or else the current one
+func (cfg *Config) toolsArtifactPaths(v Version) ([]string, error) {
+	var spec *ToolsSpec
+	var err error
+	if cfg.target == nil {
+		spec, err = MakeToolsSpec(v)
+	} else {
+		spec, err = NewToolsSpec(v, cfg.target.platform, cfg.target.arch, cfg.target.osName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return spec.artifactPaths()
+}
+
+// toolsReleases returns the MongoDB Database Tools release manifest, from the cache if it is recent enough or
+// if it can not be downloaded
+func (cfg *Config) toolsReleases(ctx context.Context) (*toolsReleasesManifest, error) {
+	manifest := &toolsReleasesManifest{}
+	err := cfg.loadManifest(ctx, "MongoDB Database Tools release manifest", toolsReleasesManifestName, toolsReleasesCacheName, func(filename string) error {
+		content, err := afs.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(content, manifest); err != nil {
+			return err
+		}
+		if len(manifest.Versions) == 0 {
+			return errors.New("no versions found in the MongoDB Database Tools release manifest")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Version returns the MongoDB Database Tools version the config is for, once resolved
+func (tc *ToolsConfig) Version() Version {
+	return tc.version
+}
+
+// ToolPath returns the path to the named executable file of the tools, e.g. "mongorestore"
+func (tc *ToolsConfig) ToolPath(name string) string {
+	return path.Join(tc.cacheDir, name)
+}
+
+// GetDatabaseTools ensures the MongoDB Database Tools are in the cache, downloading them if not already present.
+// The archive is verified against the SHA-256 published in the release manifest before the tools are extracted.
+// As for GetMongoDB, concurrent calls are serialised through a lock on the cache entry, the offline mode
+// only uses the cache, and the cached tools are verified against their manifest if enabled
+func GetDatabaseTools(ctx context.Context, tc ToolsConfig) error {
+	// The lock and the last use are kept per cache entry, identified by one of its binaries
+	entryPath := tc.ToolPath(databaseTools[0])
+
+	existsInCache, existsErr := tc.inCache(ctx)
+	if existsErr != nil {
+		log.Error(ctx, "error checking cache", existsErr)
+		return existsErr
+	}
+	if existsInCache {
+		log.Info(ctx, "MongoDB Database Tools found in cache", log.Data{"dir": tc.cacheDir})
+		if err := touchCacheEntry(entryPath); err != nil {
+			log.Error(ctx, "error recording use of cache entry", err, log.Data{"dir": tc.cacheDir})
+		}
+		return nil
+	} else if tc.cfg.offline {
+		available, listErr := cachedVersions(tc.cacheDir, databaseTools)
+		if listErr != nil {
+			log.Error(ctx, "error listing cached versions", listErr)
+		}
+		return &NotCachedError{Product: "MongoDB Database Tools", Version: tc.version.String(), Available: available}
+	}
+
+	unlockEntry, lockErr := lockCacheEntry(ctx, entryPath)
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlockEntry()
+
+	existsInCache, existsErr = tc.inCache(ctx)
+	if existsErr != nil {
+		log.Error(ctx, "error checking cache", existsErr)
+		return existsErr
+	}
+	if existsInCache {
+		log.Info(ctx, "MongoDB Database Tools downloaded by another process found in cache", log.Data{"dir": tc.cacheDir})
+		return nil
+	}
+
+	return downloadDatabaseTools(ctx, tc)
+}
+
+// inCache checks whether all the tools are in the cache and, if the verification of the cache is enabled,
+// whether they match their manifest
+func (tc *ToolsConfig) inCache(ctx context.Context) (bool, error) {
+	exists, err := hasBinaries(tc.cacheDir, databaseTools)
+	if err != nil || !exists || !tc.cfg.verifyCache {
+		return exists, err
+	}
+
+	if err = verifyEntry(tc.cacheDir); err != nil {
+		log.Warn(ctx, "Cached MongoDB Database Tools do not match their manifest", log.Data{"dir": tc.cacheDir, "error": err.Error()})
+		return false, nil
+	}
+	return true, nil
+}
+
+// downloadDatabaseTools downloads the tools archive, verifies its checksum and signature
+// and stores the tools in the cache
+func downloadDatabaseTools(ctx context.Context, tc ToolsConfig) error {
+	downloadStartTime := time.Now()
+	src := tc.cfg.source
+	location := src.Location(tc.artifact)
+
+	downloadedFile, downloadErr := downloadFile(ctx, src, tc.artifact, tc.cfg.progress)
+	if downloadErr != nil {
+		log.Error(ctx, "error downloading file", downloadErr, log.Data{"url": location})
+		return downloadErr
+	}
+
+	defer func() {
+		_ = downloadedFile.Close()
+		_ = afs.Remove(downloadedFile.Name())
+	}()
+
+	archiveSize := contentSize(downloadedFile)
+	checksum, sumErr := sha256Sum(downloadedFile.Name(), newProgressWriter(tc.cfg.progress, PhaseVerifyingChecksum, archiveSize))
+	if sumErr != nil {
+		log.Error(ctx, "error calculating SHA256 sum", sumErr)
+		return sumErr
+	}
+	if checksum != tc.checksum {
+		return &ChecksumMismatchError{Name: location, Expected: tc.checksum, Actual: checksum}
+	}
+	log.Info(ctx, "checksum verified successfully", log.Data{"url": location})
+
+	// The checksum comes from the same source as the archive: the signature proves where they both come from
+	key, keyErr := tc.cfg.signingKeyNamed(ctx, databaseToolsKeySeries, "database-tools")
+	if keyErr != nil {
+		return keyErr
+	}
+	keyFingerprint, sigErr := checkSignature(ctx, *tc.cfg, src, tc.artifact+".sig", key, downloadedFile.Name())
+	if sigErr != nil {
+		log.Error(ctx, "error verifying integrity of MongoDB Database Tools package", sigErr, log.Data{"url": location})
+		return sigErr
+	}
+	log.Info(ctx, "signature verified successfully", log.Data{"url": location + ".sig", "key": keyFingerprint})
+
+	var tmpFiles map[string]string
+	var extractErr error
+	if strings.HasSuffix(tc.artifact, ".zip") {
+		tmpFiles, extractErr = extractZipBins(ctx, downloadedFile, databaseTools)
+	} else {
+		tmpFiles, extractErr = extractMongoBins(ctx, downloadedFile, databaseTools, newProgressWriter(tc.cfg.progress, PhaseExtracting, archiveSize))
+	}
+	if extractErr != nil {
+		return &ExtractionError{URL: location, Err: extractErr}
+	}
+	if tc.cfg.progress != nil {
+		tc.cfg.progress(Progress{Phase: PhaseExtracting, Bytes: archiveSize, Total: archiveSize})
+	}
+
+	manifest := &Manifest{
+		Source:         location,
+		TarballSHA256:  checksum,
+		KeyFingerprint: keyFingerprint,
+	}
+	if installErr := installBinaries(ctx, tc.cacheDir, tmpFiles, manifest); installErr != nil {
+		return installErr
+	}
+
+	log.Info(ctx, "MongoDB Database Tools downloaded and stored in cache", log.Data{"dir": tc.cacheDir, "ellapsed": time.Since(downloadStartTime).String()})
+
+	return nil
+}
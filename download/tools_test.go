@@ -0,0 +1,393 @@
+package download
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/spf13/afero"
+)
+
+// testToolsArchive returns a tarball, or a zip archive, holding the database tools in the layout of the published archives
+func testToolsArchive(t *testing.T, dir string, zipped bool) []byte {
+	buf := new(bytes.Buffer)
+	if zipped {
+		zw := zip.NewWriter(buf)
+		for _, name := range databaseTools {
+			w, err := zw.Create(dir + "/bin/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprint(w, name)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range databaseTools {
+		if err := tw.WriteHeader(&tar.Header{Name: dir + "/bin/" + name, Mode: 0755, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(tw, name)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestToolsSpec(t *testing.T) {
+	var originalGoOs = goOS
+	var originalGoArch = goArch
+	var originalGetEnv = getEnv
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	Convey("Given a version of the MongoDB Database Tools", t, func() {
+		version := Version{Major: 100, Minor: 9, Patch: 4}
+
+		Convey("When a spec is made for a Linux build", func() {
+			spec, err := NewToolsSpec(version, "linux", "arm64", "ubuntu2204")
+
+			Convey("Then the artifact is the tarball for the build", func() {
+				So(err, ShouldBeNil)
+				artifact, err := spec.GetArtifactPath()
+				So(err, ShouldBeNil)
+				So(artifact, ShouldEqual, "tools/db/mongodb-database-tools-ubuntu2204-arm64-100.9.4.tgz")
+			})
+		})
+
+		Convey("When a spec is made for a Linux build with the aarch64 architecture", func() {
+			spec, err := NewToolsSpec(version, "linux", "aarch64", "ubuntu2204")
+
+			Convey("Then the artifact is named after arm64, as all the tools archives", func() {
+				So(err, ShouldBeNil)
+				artifact, err := spec.GetArtifactPath()
+				So(err, ShouldBeNil)
+				So(artifact, ShouldEqual, "tools/db/mongodb-database-tools-ubuntu2204-arm64-100.9.4.tgz")
+			})
+		})
+
+		Convey("When a spec is made for MacOS", func() {
+			spec, err := NewToolsSpec(version, "osx", "x86_64", "ignored")
+
+			Convey("Then the artifact is the zip archive for the architecture", func() {
+				So(err, ShouldBeNil)
+				artifact, err := spec.GetArtifactPath()
+				So(err, ShouldBeNil)
+				So(artifact, ShouldEqual, "tools/db/mongodb-database-tools-macos-x86_64-100.9.4.zip")
+			})
+		})
+
+		Convey("When a spec is made for an unsupported platform", func() {
+			spec, err := NewToolsSpec(version, "windows", "x86_64", "")
+
+			Convey("Then an UnsupportedSystemError is returned", func() {
+				So(spec, ShouldBeNil)
+				So(err, ShouldResemble, &UnsupportedSystemError{msg: "platform windows not supported"})
+			})
+		})
+
+		Convey("When a spec is made for the current Linux distribution on ARM", func() {
+			goOS = "linux"
+			goArch = "arm64"
+			getEnv = func(string) string { return "" }
+			afs.WriteFile(etcOsReleaseFileName, []byte("ID=rocky\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=8.9\n"), 0744)
+			spec, err := MakeToolsSpec(version)
+
+			Convey("Then the newest build for the distribution version on ARM is used", func() {
+				So(err, ShouldBeNil)
+				So(spec.OSName, ShouldEqual, "rhel82")
+				So(spec.Arch, ShouldEqual, "arm64")
+			})
+
+			Reset(func() {
+				goOS = originalGoOs
+				goArch = originalGoArch
+				getEnv = originalGetEnv
+				afs.Remove(etcOsReleaseFileName)
+			})
+		})
+
+		Convey("When a spec is made for the current Linux distribution", func() {
+			goOS = "linux"
+			goArch = "amd64"
+			getEnv = func(string) string { return "" }
+
+			for osrelease, expected := range map[string]string{
+				"ID=ubuntu\nVERSION_ID=24.04\n":                              "ubuntu2404",
+				"ID=ubuntu\nVERSION_ID=16.04\n":                              "ubuntu1604",
+				"ID=rocky\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=9.3\n": "rhel90",
+				"ID=rocky\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=8.9\n": "rhel80",
+			} {
+				afs.WriteFile(etcOsReleaseFileName, []byte(osrelease), 0744)
+				spec, err := MakeToolsSpec(version)
+
+				Convey("Then the newest build for the distribution version is used for "+expected, func() {
+					So(err, ShouldBeNil)
+					So(spec.OSName, ShouldEqual, expected)
+					So(spec.Arch, ShouldEqual, "x86_64")
+				})
+			}
+
+			Reset(func() {
+				goOS = originalGoOs
+				goArch = originalGoArch
+				getEnv = originalGetEnv
+				afs.Remove(etcOsReleaseFileName)
+			})
+		})
+	})
+}
+
+func TestGetDatabaseTools(t *testing.T) {
+	var originalGetEnv = getEnv
+	var originalStderrIsTerminal = stderrIsTerminal
+	var originalRetryBaseDelay = retryBaseDelay
+	testCtx := context.Background()
+	pinTestKey(t)
+
+	// Use a memory backed filesystem (no persistence)
+	afs = afero.Afero{Fs: afero.NewMemMapFs()}
+
+	signer, publicKey := testSigningEntity(t)
+	other, _ := testSigningEntity(t)
+	linuxName := "mongodb-database-tools-ubuntu2204-x86_64-100.9.4"
+	macosName := "mongodb-database-tools-macos-arm64-100.9.4"
+	archives := map[string][]byte{
+		"/tools/db/" + linuxName + ".tgz": testToolsArchive(t, linuxName, false),
+		"/tools/db/" + macosName + ".zip": testToolsArchive(t, macosName, true),
+		// Published with the wrong checksum
+		"/tools/db/mongodb-database-tools-ubuntu2204-x86_64-100.9.1.tgz": testToolsArchive(t, "tools", false),
+	}
+	checksum := func(name string) string {
+		sum := sha256.Sum256(archives[name])
+		return hex.EncodeToString(sum[:])
+	}
+	// 100.10.0 is only available for another platform
+	releases := fmt.Sprintf(`{"versions": [
+		{"version": "100.10.0", "downloads": [{"archive": {"url": "https://example.com/tools/db/mongodb-database-tools-rhel90-x86_64-100.10.0.tgz", "sha256": "x"}}]},
+		{"version": "100.9.4", "downloads": [
+			{"archive": {"url": "https://example.com/tools/db/mongodb-database-tools-rhel82-aarch64-100.9.4.tgz", "sha256": "x"}},
+			{"archive": {"url": "https://example.com/tools/db/%s.tgz", "sha256": "%s"}},
+			{"archive": {"url": "https://example.com/tools/db/%s.zip", "sha256": "%s"}}
+		]},
+		{"version": "100.9.1", "downloads": [{"archive": {"url": "https://example.com/tools/db/mongodb-database-tools-ubuntu2204-x86_64-100.9.1.tgz", "sha256": "%s"}}]},
+		{"version": "100.8.0", "downloads": [{"archive": {"url": "https://example.com/tools/db/mongodb-database-tools-ubuntu2204-x86_64-100.8.0.tgz", "sha256": "x"}}]}
+	]}`, linuxName, checksum("/tools/db/"+linuxName+".tgz"), macosName, checksum("/tools/db/"+macosName+".zip"), strings.Repeat("0", 64))
+
+	Convey("Given a server publishing the signed MongoDB Database Tools", t, func() {
+		pinnedKeyFingerprints["database-tools"] = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+		signatures := map[string][]byte{}
+		for name, archive := range archives {
+			signatures[name+".sig"] = testSignature(t, signer, archive)
+		}
+		var requests []string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.Path)
+			switch r.URL.Path {
+			case "/tools/db/release.json":
+				fmt.Fprint(w, releases)
+				return
+			case "/static/pgp/database-tools.asc":
+				_, _ = w.Write(publicKey)
+				return
+			}
+			archive, ok := archives[r.URL.Path]
+			if !ok {
+				archive, ok = signatures[r.URL.Path]
+			}
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write(archive)
+		}))
+		cacheRoot, _ := afs.TempDir("", "")
+		getEnv = func(string) string { return "" }
+		stderrIsTerminal = func() bool { return false }
+		retryBaseDelay = time.Millisecond
+		opts := []ConfigOption{
+			WithSource(NewHTTPSource(ts.URL, nil)),
+			WithCacheRoot(cacheRoot),
+			WithPlatform("linux", "x86_64", "ubuntu2204"),
+		}
+
+		Reset(func() {
+			ts.Close()
+			getEnv = originalGetEnv
+			stderrIsTerminal = originalStderrIsTerminal
+			retryBaseDelay = originalRetryBaseDelay
+		})
+
+		for spec, expected := range map[string]string{
+			"100.9.4": "100.9.4",
+			"100.9":   "100.9.4",
+			"latest":  "100.9.4",
+			"<100.9":  "100.8.0",
+		} {
+			Convey(fmt.Sprintf("When the version %q is resolved", spec), func() {
+				tc, err := NewToolsConfig(testCtx, spec, opts...)
+
+				Convey("Then the latest matching release available for the platform is used", func() {
+					So(err, ShouldBeNil)
+					version := tc.Version()
+					So(version.String(), ShouldEqual, expected)
+				})
+			})
+		}
+
+		Convey("When the version is resolved for a Linux ARM build named after aarch64", func() {
+			tc, err := NewToolsConfig(testCtx, "100.9", WithSource(NewHTTPSource(ts.URL, nil)), WithCacheRoot(cacheRoot), WithPlatform("linux", "arm64", "rhel82"))
+
+			Convey("Then the archive named after aarch64 is used", func() {
+				So(err, ShouldBeNil)
+				So(tc.artifact, ShouldEqual, "tools/db/mongodb-database-tools-rhel82-aarch64-100.9.4.tgz")
+			})
+		})
+
+		Convey("When a version with no release for the platform is requested", func() {
+			tc, err := NewToolsConfig(testCtx, "100.10.0", opts...)
+
+			Convey("Then an UnsupportedMongoVersionError is returned", func() {
+				So(tc, ShouldBeNil)
+				So(err, ShouldResemble, &UnsupportedMongoVersionError{
+					version: "100.10.0",
+					msg:     "no release of the MongoDB Database Tools available for this platform matches the version",
+				})
+			})
+		})
+
+		Convey("When the tools are installed", func() {
+			tc, err := NewToolsConfig(testCtx, "100.9", opts...)
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then every tool is stored in the cache root", func() {
+				So(err, ShouldBeNil)
+				So(tc.ToolPath("mongorestore"), ShouldEqual, path.Join(cacheRoot, linuxName+".tgz", "mongorestore"))
+				for _, name := range databaseTools {
+					content, err := afs.ReadFile(tc.ToolPath(name))
+					So(err, ShouldBeNil)
+					So(string(content), ShouldEqual, name)
+				}
+
+				manifest, err := readManifest(path.Join(cacheRoot, linuxName+".tgz"))
+				So(err, ShouldBeNil)
+				So(manifest.Source, ShouldEqual, ts.URL+"/tools/db/"+linuxName+".tgz")
+				So(manifest.KeyFingerprint, ShouldEqual, pinnedKeyFingerprints["database-tools"])
+				So(manifest.Binaries, ShouldHaveLength, len(databaseTools))
+			})
+
+			Convey("Then the cached tools are used afterwards, even in offline mode", func() {
+				requests = nil
+				offlineTc, err := NewToolsConfig(testCtx, "100.9.4", append(opts, WithOffline(true))...)
+				So(err, ShouldBeNil)
+				So(GetDatabaseTools(testCtx, *offlineTc), ShouldBeNil)
+				So(requests, ShouldBeEmpty)
+			})
+
+			Convey("Then they are listed by the NotCachedError of another version in offline mode", func() {
+				offlineTc, err := NewToolsConfig(testCtx, "100.8.0", append(opts, WithOffline(true))...)
+				So(err, ShouldBeNil)
+				err = GetDatabaseTools(testCtx, *offlineTc)
+				So(err, ShouldResemble, &NotCachedError{Product: "MongoDB Database Tools", Version: "100.8.0", Available: []string{"100.9.4"}})
+			})
+
+			Convey("Then the cache lists them", func() {
+				entries, err := NewCacheAt(cacheRoot).List()
+				So(err, ShouldBeNil)
+				So(entries, ShouldHaveLength, 1)
+				So(entries[0].Version, ShouldEqual, "100.9.4")
+				So(entries[0].Platform, ShouldEqual, "database-tools-ubuntu2204-x86_64")
+			})
+		})
+
+		Convey("When the tools are installed for MacOS", func() {
+			tc, err := NewToolsConfig(testCtx, "100.9.4", WithSource(NewHTTPSource(ts.URL, nil)), WithCacheRoot(cacheRoot), WithPlatform("osx", "arm64", ""))
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then they are extracted from the zip archive", func() {
+				So(err, ShouldBeNil)
+				content, err := afs.ReadFile(tc.ToolPath("mongodump"))
+				So(err, ShouldBeNil)
+				So(string(content), ShouldEqual, "mongodump")
+			})
+		})
+
+		Convey("When the archive does not match the published checksum", func() {
+			tc, err := NewToolsConfig(testCtx, "100.9.1", opts...)
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then a ChecksumMismatchError is returned and nothing is cached", func() {
+				var checksumErr *ChecksumMismatchError
+				So(errors.As(err, &checksumErr), ShouldBeTrue)
+				So(checksumErr.Name, ShouldEqual, ts.URL+"/tools/db/mongodb-database-tools-ubuntu2204-x86_64-100.9.1.tgz")
+				exists, _ := afs.Exists(tc.ToolPath("mongodump"))
+				So(exists, ShouldBeFalse)
+			})
+		})
+
+		Convey("When the tools are not cached in offline mode", func() {
+			_, err := NewToolsConfig(testCtx, "100.9.4", opts...)
+			So(err, ShouldBeNil)
+			tc, err := NewToolsConfig(testCtx, "100.9.4", append(opts, WithOffline(true))...)
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then a NotCachedError naming the tools is returned", func() {
+				So(err, ShouldResemble, &NotCachedError{Product: "MongoDB Database Tools", Version: "100.9.4"})
+				So(err.Error(), ShouldStartWith, "MongoDB Database Tools version \"100.9.4\" not found in cache")
+			})
+		})
+
+		Convey("When the archive is not signed with the pinned key", func() {
+			signatures["/tools/db/"+linuxName+".tgz.sig"] = testSignature(t, other, archives["/tools/db/"+linuxName+".tgz"])
+			tc, err := NewToolsConfig(testCtx, "100.9.4", opts...)
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then a SignatureError is returned and nothing is cached", func() {
+				var sigErr *SignatureError
+				So(errors.As(err, &sigErr), ShouldBeTrue)
+				So(sigErr.URL, ShouldEqual, ts.URL+"/tools/db/"+linuxName+".tgz.sig")
+				exists, _ := afs.Exists(tc.ToolPath("mongodump"))
+				So(exists, ShouldBeFalse)
+			})
+		})
+
+		Convey("When no key is pinned for the tools", func() {
+			delete(pinnedKeyFingerprints, "database-tools")
+			tc, err := NewToolsConfig(testCtx, "100.9.4", opts...)
+			So(err, ShouldBeNil)
+			err = GetDatabaseTools(testCtx, *tc)
+
+			Convey("Then an UnknownKeyError is returned unless trust on first use is enabled", func() {
+				So(err, ShouldResemble, &UnknownKeyError{Series: "database-tools"})
+			})
+		})
+	})
+}
@@ -2,20 +2,35 @@ package download
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-// Version represents a version (Major.Minor.Patch)
+// prereleaseRegexp matches the pre-release identifiers of a version, e.g. rc3 in 8.0.0-rc3
+var prereleaseRegexp = regexp.MustCompile(`^[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*$`)
+
+// Version represents a version (Major.Minor.Patch), optionally followed by pre-release identifiers
+// (Major.Minor.Patch-Prerelease), e.g. 8.0.0-rc3
 type Version struct {
 	Major int
 	Minor int
 	Patch int
+	// Prerelease holds the dot separated pre-release identifiers, e.g. rc3. It is empty for releases
+	Prerelease string
 }
 
 // NewVersion parses the version string and creates a Version object
 func NewVersion(version string) (*Version, error) {
-	versionParts := strings.Split(version, ".")
+	release, prerelease, isPrerelease := strings.Cut(version, "-")
+	if isPrerelease && !prereleaseRegexp.MatchString(prerelease) {
+		return nil, &UnsupportedMongoVersionError{
+			version: version,
+			msg:     "could not parse pre-release identifiers",
+		}
+	}
+
+	versionParts := strings.Split(release, ".")
 	if len(versionParts) != 3 {
 		return nil, &UnsupportedMongoVersionError{
 			version: version,
@@ -48,31 +63,118 @@ func NewVersion(version string) (*Version, error) {
 	}
 
 	return &Version{
-		Major: majorVersion,
-		Minor: minorVersion,
-		Patch: patchVersion,
+		Major:      majorVersion,
+		Minor:      minorVersion,
+		Patch:      patchVersion,
+		Prerelease: prerelease,
 	}, nil
 }
 
 // String returns the string representation of a Version:
-// Major.Minor.Patch
+// Major.Minor.Patch, or Major.Minor.Patch-Prerelease for pre-releases
 func (v *Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	return s
 }
 
-// IsGreaterOrEqual checks if the version is greater or equal than another version
+// IsPrerelease reports whether the version is a pre-release, e.g. a release candidate
+func (v *Version) IsPrerelease() bool {
+	return v.Prerelease != ""
+}
+
+// IsGreaterOrEqual checks if the version is greater or equal than another version.
+// As in semantic versioning, a pre-release is lower than its release: 8.0.0-rc3 is lower than 8.0.0
 func (v *Version) IsGreaterOrEqual(major int, minor int, patch int) bool {
-	if v.Major > major {
-		return true
+	return v.Compare(Version{Major: major, Minor: minor, Patch: patch}) >= 0
+}
+
+// Compare returns -1, 0 or 1 if the version is respectively lower than, equal to or greater than the other version.
+// The precedence is the one of semantic versioning: a pre-release is lower than its release, and pre-releases
+// are ordered by their identifiers, numeric ones numerically, e.g. 8.0.0-rc2 < 8.0.0-rc10 < 8.0.0
+func (v *Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return comparePrereleases(v.Prerelease, other.Prerelease)
+}
+
+// comparePrereleases compares the pre-release identifiers of 2 versions with the same Major.Minor.Patch
+func comparePrereleases(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aIDs, bIDs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		if c := compareIdentifiers(aIDs[i], bIDs[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(aIDs) < len(bIDs):
+		return -1
+	case len(aIDs) > len(bIDs):
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers compares 2 pre-release identifiers: numeric identifiers are compared numerically and are
+// lower than alphanumeric ones, which are compared lexically. Within an alphanumeric identifier, a trailing
+// number is compared numerically too, so that rc10 is greater than rc9 as MongoDB numbers its release candidates
+func compareIdentifiers(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(aNum, bNum)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
 	}
-	if v.Major < major {
-		return false
+
+	aPrefix, aSuffix := splitTrailingNumber(a)
+	bPrefix, bSuffix := splitTrailingNumber(b)
+	if aPrefix == bPrefix && aSuffix >= 0 && bSuffix >= 0 {
+		return compareInts(aSuffix, bSuffix)
 	}
-	if v.Minor > minor {
-		return true
+	return strings.Compare(a, b)
+}
+
+// splitTrailingNumber splits an identifier such as rc10 into its prefix and trailing number,
+// which is -1 if there is none
+func splitTrailingNumber(id string) (string, int) {
+	i := len(id)
+	for i > 0 && id[i-1] >= '0' && id[i-1] <= '9' {
+		i--
 	}
-	if v.Minor < minor {
-		return false
+	n, err := strconv.Atoi(id[i:])
+	if err != nil {
+		return id, -1
+	}
+	return id[:i], n
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
 	}
-	return v.Patch >= patch
+	return 0
 }
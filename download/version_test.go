@@ -0,0 +1,81 @@
+package download
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestNewVersion(t *testing.T) {
+	Convey("Given a release candidate version", t, func() {
+		version, err := NewVersion("8.0.0-rc3")
+
+		Convey("Then its pre-release identifiers are parsed", func() {
+			So(err, ShouldBeNil)
+			So(*version, ShouldResemble, Version{Major: 8, Minor: 0, Patch: 0, Prerelease: "rc3"})
+			So(version.IsPrerelease(), ShouldBeTrue)
+			So(version.String(), ShouldEqual, "8.0.0-rc3")
+		})
+	})
+
+	Convey("Given a release version", t, func() {
+		version, err := NewVersion("7.0.4")
+
+		Convey("Then it has no pre-release identifiers", func() {
+			So(err, ShouldBeNil)
+			So(version.IsPrerelease(), ShouldBeFalse)
+			So(version.String(), ShouldEqual, "7.0.4")
+		})
+	})
+
+	Convey("Given versions with invalid pre-release identifiers", t, func() {
+		for _, v := range []string{"8.0.0-", "8.0.0-rc..1", "8.0.0-rc_1"} {
+			_, err := NewVersion(v)
+
+			Convey("Then an error is returned for "+v, func() {
+				So(err, ShouldResemble, &UnsupportedMongoVersionError{
+					version: v,
+					msg:     "could not parse pre-release identifiers",
+				})
+			})
+		}
+	})
+}
+
+func TestVersionPrecedence(t *testing.T) {
+	Convey("Given versions in ascending order of precedence", t, func() {
+		ordered := []string{
+			"7.0.4",
+			"8.0.0-0",
+			"8.0.0-alpha",
+			"8.0.0-alpha.1",
+			"8.0.0-rc0",
+			"8.0.0-rc2",
+			"8.0.0-rc10",
+			"8.0.0",
+			"8.0.1",
+		}
+
+		Convey("Then Compare orders them", func() {
+			for i := range ordered {
+				v, err := NewVersion(ordered[i])
+				So(err, ShouldBeNil)
+				So(v.Compare(*v), ShouldEqual, 0)
+				for j := i + 1; j < len(ordered); j++ {
+					w, _ := NewVersion(ordered[j])
+					So(v.Compare(*w), ShouldEqual, -1)
+					So(w.Compare(*v), ShouldEqual, 1)
+				}
+			}
+		})
+	})
+
+	Convey("Given a release candidate", t, func() {
+		version := &Version{Major: 8, Minor: 0, Patch: 0, Prerelease: "rc3"}
+
+		Convey("Then it is lower than its release and greater than the previous ones", func() {
+			So(version.IsGreaterOrEqual(8, 0, 0), ShouldBeFalse)
+			So(version.IsGreaterOrEqual(7, 0, 99), ShouldBeTrue)
+		})
+	})
+}
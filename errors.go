@@ -0,0 +1,37 @@
+package mim
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ElectionTimeoutError is used to indicate that a replica set did not elect
+// a writable primary before the context was done
+type ElectionTimeoutError struct {
+	// ReplicaSet is the name of the replica set
+	ReplicaSet string
+	// MemberStates holds the last state seen for each member, keyed by host
+	MemberStates map[string]string
+	// Err is the context error that ended the wait
+	Err error
+}
+
+func (err *ElectionTimeoutError) Error() string {
+	hosts := make([]string, 0, len(err.MemberStates))
+	for host := range err.MemberStates {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	states := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		states = append(states, host+": "+err.MemberStates[host])
+	}
+
+	return fmt.Sprintf("no primary elected in replica set %s (%s): %v", err.ReplicaSet, strings.Join(states, ", "), err.Err)
+}
+
+func (err *ElectionTimeoutError) Unwrap() error {
+	return err.Err
+}
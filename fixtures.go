@@ -0,0 +1,232 @@
+package mim
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ONSdigital/log.go/v2/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultFixtureBatchSize is the number of documents inserted at once if no batch size is given
+const defaultFixtureBatchSize = 1000
+
+// fixtureExtensions are the extensions of the fixture files, and whether they only hold NDJSON
+var fixtureExtensions = map[string]bool{
+	".json":   false,
+	".ndjson": true,
+}
+
+// FixtureOption defines the template function for defining options that may be used to load fixtures
+// The options available are given by the exported variables: WithDropCollections, WithBatchSize
+type FixtureOption func(*fixtureConfig)
+
+type fixtureConfig struct {
+	drop      bool
+	batchSize int
+}
+
+var (
+	WithDropCollections = func(d bool) FixtureOption { return func(c *fixtureConfig) { c.drop = d } }
+	WithBatchSize       = func(n int) FixtureOption { return func(c *fixtureConfig) { c.batchSize = n } }
+)
+
+// fixtureFile is a fixture file, and the collection its documents are inserted into
+type fixtureFile struct {
+	path       string
+	database   string
+	collection string
+}
+
+func (f fixtureFile) namespace() string {
+	return f.database + "." + f.collection
+}
+
+// LoadFixtures inserts the documents of the fixture files found in the given file system into the server,
+// with 0 or more options as defined: WithDropCollections, WithBatchSize
+//
+// The files are named <database>/<collection>.json or <database>/<collection>.ndjson, relative to the root
+// of the file system; other files are ignored. A .json file holds either an array of documents or one document
+// per line (NDJSON), and a .ndjson file one document per line. The documents may be in canonical or relaxed
+// Extended JSON. Use os.DirFS to load fixtures from a directory, or fs.Sub to load them from a subdirectory of
+// an embed.FS
+// If true is provided in WithDropCollections, the collections are dropped before their documents are inserted
+// The documents are inserted by batches of the size given by WithBatchSize, 1000 if not provided
+//
+// It returns the number of documents inserted, keyed by namespace (<database>.<collection>)
+func (s *Server) LoadFixtures(ctx context.Context, fsys fs.FS, opts ...FixtureOption) (map[string]int, error) {
+	cfg := &fixtureConfig{batchSize: defaultFixtureBatchSize}
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.batchSize < 1 {
+		return nil, fmt.Errorf("invalid batch size for the fixtures: %d", cfg.batchSize)
+	}
+
+	files, err := findFixtures(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.adminClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, f := range files {
+		coll := client.Database(f.database).Collection(f.collection)
+
+		if _, seen := counts[f.namespace()]; !seen {
+			counts[f.namespace()] = 0
+			if cfg.drop {
+				if err = coll.Drop(ctx); err != nil {
+					return counts, fmt.Errorf("could not drop collection %s: %w", f.namespace(), err)
+				}
+			}
+		}
+
+		n, err := readFixture(fsys, f.path, cfg.batchSize, func(docs []interface{}) error {
+			_, err := coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+			return err
+		})
+		counts[f.namespace()] += n
+		if err != nil {
+			return counts, fmt.Errorf("could not load fixture %s: %w", f.path, err)
+		}
+	}
+
+	log.Info(ctx, "fixtures loaded", log.Data{"counts": counts})
+
+	return counts, nil
+}
+
+// findFixtures returns the fixture files of the file system, sorted by path
+func findFixtures(fsys fs.FS) ([]fixtureFile, error) {
+	dbs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixtures: %w", err)
+	}
+
+	var files []fixtureFile
+	for _, db := range dbs {
+		if !db.IsDir() {
+			continue
+		}
+		entries, err := fs.ReadDir(fsys, db.Name())
+		if err != nil {
+			return nil, fmt.Errorf("could not read fixtures of database %s: %w", db.Name(), err)
+		}
+		for _, entry := range entries {
+			ext := path.Ext(entry.Name())
+			if _, ok := fixtureExtensions[ext]; !ok || entry.IsDir() {
+				continue
+			}
+			files = append(files, fixtureFile{
+				path:       path.Join(db.Name(), entry.Name()),
+				database:   db.Name(),
+				collection: strings.TrimSuffix(entry.Name(), ext),
+			})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// readFixture parses the documents of the given fixture file, and passes them to insert by batches of the given size.
+// It returns the number of documents inserted
+func readFixture(fsys fs.FS, name string, batchSize int, insert func(docs []interface{}) error) (int, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	isArray := first == '[' && !fixtureExtensions[path.Ext(name)]
+	decoder := json.NewDecoder(reader)
+	if isArray {
+		// Consume the opening bracket, so that the documents of the array are decoded one at a time
+		if _, err = decoder.Token(); err != nil {
+			return 0, err
+		}
+	}
+
+	inserted := 0
+	batch := make([]interface{}, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := insert(batch); err != nil {
+			return err
+		}
+		inserted += len(batch)
+		batch = make([]interface{}, 0, batchSize)
+		return nil
+	}
+
+	for i := 1; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return inserted, fmt.Errorf("invalid document %d: %w", i, err)
+		}
+		var doc bson.D
+		if err = bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+			return inserted, fmt.Errorf("invalid document %d: %w", i, err)
+		}
+
+		batch = append(batch, doc)
+		if len(batch) == batchSize {
+			if err = flush(); err != nil {
+				return inserted, err
+			}
+		}
+	}
+
+	if isArray {
+		if _, err = decoder.Token(); err != nil {
+			return inserted, fmt.Errorf("invalid array of documents: %w", err)
+		}
+	}
+	if decoder.More() {
+		return inserted, errors.New("unexpected content after the array of documents")
+	}
+
+	return inserted, flush()
+}
+
+// firstNonSpace returns the first byte of the reader that is not a white space, without consuming it
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b)) {
+			return b, reader.UnreadByte()
+		}
+	}
+}
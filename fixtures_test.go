@@ -0,0 +1,231 @@
+package mim
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//go:embed testdata/fixtures
+var embeddedFixtures embed.FS
+
+// testFixtures returns the fixtures embedded from testdata/fixtures
+func testFixtures() fs.FS {
+	fixtures, err := fs.Sub(embeddedFixtures, "testdata/fixtures")
+	So(err, ShouldBeNil)
+	return fixtures
+}
+
+func TestFindFixtures(t *testing.T) {
+	Convey("Given a file system with fixtures and other files", t, func() {
+		fsys := fstest.MapFS{
+			"README.md":              {Data: []byte("fixtures")},
+			"db1/coll1.json":         {Data: []byte("[]")},
+			"db1/coll2.ndjson":       {Data: []byte("")},
+			"db1/notes.txt":          {Data: []byte("")},
+			"db2/coll1.json":         {Data: []byte("[]")},
+			"db2/nested/coll3.json":  {Data: []byte("[]")},
+			"db2/nested/coll4.jsonl": {Data: []byte("")},
+		}
+
+		Convey("When the fixtures are looked up", func() {
+			files, err := findFixtures(fsys)
+
+			Convey("Then the <database>/<collection> files are found, sorted by path", func() {
+				So(err, ShouldBeNil)
+				So(files, ShouldResemble, []fixtureFile{
+					{path: "db1/coll1.json", database: "db1", collection: "coll1"},
+					{path: "db1/coll2.ndjson", database: "db1", collection: "coll2"},
+					{path: "db2/coll1.json", database: "db2", collection: "coll1"},
+				})
+			})
+		})
+	})
+
+	Convey("Given fixtures embedded with go:embed", t, func() {
+		fixtures := testFixtures()
+
+		Convey("When the fixtures are looked up", func() {
+			files, err := findFixtures(fixtures)
+
+			Convey("Then they are all found", func() {
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 3)
+				So(files[0].namespace(), ShouldEqual, "shop.orders")
+				So(files[1].namespace(), ShouldEqual, "shop.products")
+				So(files[2].namespace(), ShouldEqual, "users.accounts")
+			})
+		})
+	})
+}
+
+func TestReadFixture(t *testing.T) {
+	Convey("Given fixture files in the supported formats", t, func() {
+		fsys := fstest.MapFS{
+			"db/canonical.json": {Data: []byte(`[
+				{"_id": {"$oid": "5f1e3f1e9d3b2a0001a1b2c3"}, "n": {"$numberInt": "1"}, "at": {"$date": {"$numberLong": "1704164645000"}}},
+				{"_id": {"$oid": "5f1e3f1e9d3b2a0001a1b2c4"}, "n": {"$numberInt": "2"}, "at": {"$date": {"$numberLong": "1706933106000"}}}
+			]`)},
+			"db/relaxed.json": {Data: []byte(`  [{"_id": 1, "n": 1, "at": {"$date": "2024-01-02T03:04:05Z"}}, {"_id": 2, "n": 2.5}, {"_id": 3}]`)},
+			"db/lines.json":   {Data: []byte("{\"_id\": 1}\n{\"_id\": 2}\n\n{\"_id\": 3}\n")},
+			"db/lines.ndjson": {Data: []byte("{\"_id\": 1}\n{\"_id\": {\"$numberLong\": \"2\"}}\n")},
+			"db/empty.json":   {Data: []byte("\n  \n")},
+			"db/invalid.json": {Data: []byte(`[{"_id": 1}, {"_id": {"$oid": "not an oid"}}]`)},
+			"db/broken.json":  {Data: []byte(`[{"_id": 1}, {"_id": 2}`)},
+			"db/array.ndjson": {Data: []byte(`[{"_id": 1}]`)},
+		}
+
+		load := func(name string, batchSize int) ([][]interface{}, int, error) {
+			var batches [][]interface{}
+			n, err := readFixture(fsys, name, batchSize, func(docs []interface{}) error {
+				batches = append(batches, docs)
+				return nil
+			})
+			return batches, n, err
+		}
+
+		Convey("When a canonical Extended JSON array is read", func() {
+			batches, n, err := load("db/canonical.json", 10)
+
+			Convey("Then its documents are decoded with their BSON types", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+				So(batches, ShouldHaveLength, 1)
+				doc := batches[0][0].(bson.D)
+				id, _ := primitive.ObjectIDFromHex("5f1e3f1e9d3b2a0001a1b2c3")
+				So(doc[0], ShouldResemble, bson.E{Key: "_id", Value: id})
+				So(doc[1], ShouldResemble, bson.E{Key: "n", Value: int32(1)})
+				So(doc[2].Value, ShouldEqual, primitive.NewDateTimeFromTime(time.UnixMilli(1704164645000)))
+			})
+		})
+
+		Convey("When a relaxed Extended JSON array is read by batches of 2", func() {
+			batches, n, err := load("db/relaxed.json", 2)
+
+			Convey("Then its documents are passed by batches", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 3)
+				So(batches, ShouldHaveLength, 2)
+				So(batches[0], ShouldHaveLength, 2)
+				So(batches[1], ShouldHaveLength, 1)
+				So(batches[0][1].(bson.D)[1], ShouldResemble, bson.E{Key: "n", Value: 2.5})
+			})
+		})
+
+		Convey("When NDJSON files are read", func() {
+			_, jsonCount, jsonErr := load("db/lines.json", 10)
+			batches, ndjsonCount, ndjsonErr := load("db/lines.ndjson", 10)
+
+			Convey("Then a document is read per line, whatever the extension", func() {
+				So(jsonErr, ShouldBeNil)
+				So(jsonCount, ShouldEqual, 3)
+				So(ndjsonErr, ShouldBeNil)
+				So(ndjsonCount, ShouldEqual, 2)
+				So(batches[0][1].(bson.D)[0], ShouldResemble, bson.E{Key: "_id", Value: int64(2)})
+			})
+		})
+
+		Convey("When an empty file is read", func() {
+			batches, n, err := load("db/empty.json", 10)
+
+			Convey("Then nothing is inserted", func() {
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 0)
+				So(batches, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a file with an invalid document is read by batches of 1", func() {
+			_, n, err := load("db/invalid.json", 1)
+
+			Convey("Then the documents before it are inserted and the invalid one is reported", func() {
+				So(n, ShouldEqual, 1)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "invalid document 2: ")
+			})
+		})
+
+		Convey("When a file with an unterminated array is read", func() {
+			_, _, err := load("db/broken.json", 10)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a NDJSON file holding an array is read", func() {
+			_, _, err := load("db/array.ndjson", 10)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "invalid document 1: ")
+			})
+		})
+
+		Convey("When the insertion fails", func() {
+			insertErr := errors.New("insert failed")
+			_, err := readFixture(fsys, "db/relaxed.json", 10, func([]interface{}) error { return insertErr })
+
+			Convey("Then the error is returned", func() {
+				So(err, ShouldEqual, insertErr)
+			})
+		})
+	})
+}
+
+func TestLoadFixtures(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("Given a server with existing data", t, func() {
+		server, err := Start(testCtx, "5.0.2")
+		So(err, ShouldBeNil)
+		defer server.Stop(testCtx)
+
+		client, err := mongo.Connect(testCtx, options.Client().ApplyURI(server.URI()))
+		So(err, ShouldBeNil)
+		defer client.Disconnect(testCtx)
+
+		products := client.Database("shop").Collection("products")
+		_, err = products.InsertOne(testCtx, bson.D{{Key: "name", Value: "stale"}})
+		So(err, ShouldBeNil)
+
+		Convey("When the embedded fixtures are loaded, dropping the collections first", func() {
+			counts, err := server.LoadFixtures(testCtx, testFixtures(), WithDropCollections(true), WithBatchSize(2))
+
+			Convey("Then the documents of every fixture are inserted and counted", func() {
+				So(err, ShouldBeNil)
+				So(counts, ShouldResemble, map[string]int{"shop.orders": 3, "shop.products": 2, "users.accounts": 2})
+
+				n, err := products.CountDocuments(testCtx, bson.D{})
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 2)
+
+				var kettle bson.M
+				So(products.FindOne(testCtx, bson.D{{Key: "name", Value: "kettle"}}).Decode(&kettle), ShouldBeNil)
+				So(kettle["price"], ShouldHaveSameTypeAs, primitive.Decimal128{})
+			})
+		})
+
+		Convey("When the fixtures are loaded without dropping the collections", func() {
+			_, err := server.LoadFixtures(testCtx, testFixtures())
+
+			Convey("Then the documents are added to the existing ones", func() {
+				So(err, ShouldBeNil)
+				n, err := products.CountDocuments(testCtx, bson.D{})
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 3)
+			})
+		})
+	})
+}
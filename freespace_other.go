@@ -0,0 +1,8 @@
+//go:build !linux
+
+package mim
+
+// diskFree returns 0 on systems other than Linux, where no RAM-backed location is used for database directories
+func diskFree(string) (uint64, error) {
+	return 0, nil
+}
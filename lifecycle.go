@@ -0,0 +1,159 @@
+package mim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/log.go/v2/log"
+)
+
+// max time allowed for the server to shut down gracefully, if the context has no deadline
+const shutdownTimeout = 10 * time.Second
+
+// max time allowed for the server to exit once it has been killed
+const killTimeout = 5 * time.Second
+
+// Shutdown stops the mongo server gracefully: it sends it a SIGTERM and waits for it to exit
+// within the context deadline, or a default timeout if the context has none.
+// If the server has not exited by then, it is killed.
+// The watcher is stopped and the database directory is removed, unless it was given with WithDatabaseDir.
+// Any error found along the way is returned
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	if err := s.terminate(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.ownsDbDir {
+		if err := os.RemoveAll(s.dbDir); err != nil {
+			errs = append(errs, fmt.Errorf("could not remove data directory %s: %w", s.dbDir, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// terminate sends a SIGTERM to the server and waits for it to exit, killing it if it does not exit
+// before the context is done. The watcher is then stopped
+func (s *Server) terminate(ctx context.Context) error {
+	s.disconnect(ctx)
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	if s.cmd != nil && s.cmd.Process != nil && !s.hasExited() {
+		pid := s.cmd.Process.Pid
+		if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil && !s.hasExited() {
+			errs = append(errs, fmt.Errorf("could not terminate %s process %d: %w", s.processName(), pid, err))
+		} else {
+			select {
+			case <-s.exited:
+				if s.exitErr != nil {
+					errs = append(errs, fmt.Errorf("%s process %d did not exit cleanly: %w", s.processName(), pid, s.exitErr))
+				}
+			case <-ctx.Done():
+				log.Warn(ctx, "mongod did not shut down in time, killing it", log.Data{"pid": pid})
+				errs = append(errs, fmt.Errorf("%s process %d did not shut down: %w", s.processName(), pid, ctx.Err()))
+			}
+		}
+	}
+
+	// Kill the server if it is still running, and the watcher
+	killCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), killTimeout)
+	defer cancel()
+	if err := s.Kill(killCtx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Kill kills the mongo server and its watcher, and waits for them to exit.
+// Unlike Stop, the database directory is kept, so the server can be started again with Start
+func (s *Server) Kill(ctx context.Context) error {
+	s.disconnect(ctx)
+
+	var errs []error
+	if s.cmd != nil && s.cmd.Process != nil && !s.hasExited() {
+		if err := s.cmd.Process.Kill(); err != nil && !s.hasExited() {
+			errs = append(errs, fmt.Errorf("could not kill %s process %d: %w", s.processName(), s.cmd.Process.Pid, err))
+		} else {
+			select {
+			case <-s.exited:
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("waiting for %s process %d to exit: %w", s.processName(), s.cmd.Process.Pid, ctx.Err()))
+			}
+		}
+	}
+
+	if s.watcherCmd != nil {
+		if err := s.watcherCmd.Process.Kill(); err != nil {
+			errs = append(errs, fmt.Errorf("could not kill watcher process %d: %w", s.watcherCmd.Process.Pid, err))
+		} else {
+			// The watcher has been killed, so the error returned only reports that
+			_ = s.watcherCmd.Wait()
+		}
+		s.watcherCmd = nil
+	}
+
+	return errors.Join(errs...)
+}
+
+// Start starts the server again after it has been killed, with the same port,
+// database directory and replica set name.
+// If the server was started as a single member replica set, it returns once it has been elected primary
+func (s *Server) Start(ctx context.Context) error {
+	if s.binPath == "" {
+		return errors.New("the server has never been started")
+	}
+	if !s.hasExited() {
+		return fmt.Errorf("the server is already running on port %d", s.port)
+	}
+
+	if err := s.start(ctx, s.binPath); err != nil {
+		return err
+	}
+
+	// The replica set configuration is kept in the database directory
+	if s.initiated {
+		if _, err := waitForPrimary(ctx, s.replSet, []*Server{s}); err != nil {
+			log.Error(ctx, "No primary elected in the replica set", err, log.Data{"replicaSet": s.replSet})
+			return err
+		}
+	}
+
+	log.Info(ctx, fmt.Sprintf("mongod restarted with the following configuration: %s", s))
+
+	return nil
+}
+
+// Restart kills the server and starts it again, keeping its port, database directory and replica set name.
+// Data only persists across restarts with a persistent storage engine, such as wiredTiger
+func (s *Server) Restart(ctx context.Context) error {
+	if err := s.Kill(ctx); err != nil {
+		return err
+	}
+	return s.Start(ctx)
+}
+
+// hasExited checks whether the server process has exited, or was never started
+func (s *Server) hasExited() bool {
+	if s.exited == nil {
+		return true
+	}
+	select {
+	case <-s.exited:
+		return true
+	default:
+		return false
+	}
+}
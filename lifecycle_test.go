@@ -0,0 +1,214 @@
+package mim
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/dp-mongodb-in-memory/monitor"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestRestart(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("Given a replica set server with some data", t, func() {
+		server, err := StartWithOptions(testCtx, "5.0.2", WithReplicaSet("rs0"))
+		So(err, ShouldBeNil)
+		defer server.Stop(testCtx)
+
+		port, dbDir := server.Port(), server.DBdir()
+		pid := server.cmd.Process.Pid
+
+		client, err := mongo.Connect(testCtx, options.Client().ApplyURI(server.URI()).SetReplicaSet("rs0"))
+		So(err, ShouldBeNil)
+		defer client.Disconnect(testCtx)
+
+		coll := client.Database("test").Collection("test")
+		_, err = coll.InsertOne(testCtx, bson.D{{Key: "a", Value: 1}})
+		So(err, ShouldBeNil)
+
+		Convey("When Restart is called", func() {
+			err := server.Restart(testCtx)
+
+			Convey("Then the server runs again with the same configuration", func() {
+				So(err, ShouldBeNil)
+				So(server.cmd.Process.Pid, ShouldNotEqual, pid)
+				So(server.Port(), ShouldEqual, port)
+				So(server.DBdir(), ShouldEqual, dbDir)
+				So(server.ReplicaSet(), ShouldEqual, "rs0")
+
+				Convey("And the data has persisted and the server accepts writes", func() {
+					count, err := coll.CountDocuments(testCtx, bson.D{})
+					So(err, ShouldBeNil)
+					So(count, ShouldEqual, 1)
+
+					_, err = coll.InsertOne(testCtx, bson.D{{Key: "a", Value: 2}})
+					So(err, ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When Kill is called", func() {
+			err := server.Kill(testCtx)
+
+			Convey("Then the server is not running but its database directory is kept", func() {
+				So(err, ShouldBeNil)
+				So(server.hasExited(), ShouldBeTrue)
+				So(server.watcherCmd, ShouldBeNil)
+				_, err = os.Stat(dbDir)
+				So(err, ShouldBeNil)
+
+				Convey("And Start runs it again", func() {
+					So(server.Start(testCtx), ShouldBeNil)
+					So(server.Port(), ShouldEqual, port)
+					So(server.watcherCmd, ShouldNotBeNil)
+					So(client.Ping(testCtx, nil), ShouldBeNil)
+				})
+			})
+		})
+
+		Convey("When Start is called on the running server", func() {
+			err := server.Start(testCtx)
+
+			Convey("Then an error is returned", func() {
+				So(err, ShouldBeError)
+			})
+		})
+	})
+
+	Convey("Given a server started with a database directory", t, func() {
+		tempDir, err := os.MkdirTemp("", "")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tempDir)
+
+		server, err := StartWithOptions(testCtx, "5.0.2", WithDatabaseDir(tempDir))
+		So(err, ShouldBeNil)
+
+		Convey("When Stop is called", func() {
+			server.Stop(testCtx)
+
+			Convey("Then the database directory is kept", func() {
+				_, err := os.Stat(tempDir)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a server started with a temporary database directory", t, func() {
+		server, err := Start(testCtx, "5.0.2")
+		So(err, ShouldBeNil)
+
+		Convey("When Stop is called", func() {
+			server.Stop(testCtx)
+
+			Convey("Then the database directory is removed", func() {
+				_, err := os.Stat(server.DBdir())
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a server that has never been started", t, func() {
+		server := &Server{}
+
+		Convey("Then Start returns an error", func() {
+			So(server.Start(testCtx), ShouldBeError, "the server has never been started")
+		})
+
+		Convey("Then Kill does nothing", func() {
+			So(server.Kill(testCtx), ShouldBeNil)
+		})
+	})
+}
+
+// runProcess runs the given shell script as if it were the server process
+func runProcess(s *Server, script string) {
+	s.cmd = exec.Command("/bin/sh", "-c", script)
+	So(s.cmd.Start(), ShouldBeNil)
+
+	exited := make(chan struct{})
+	s.exited = exited
+	go func() {
+		s.exitErr = s.cmd.Wait()
+		close(exited)
+	}()
+
+	watcher, err := monitor.Run(os.Getpid(), s.cmd.Process.Pid)
+	So(err, ShouldBeNil)
+	s.watcherCmd = watcher
+}
+
+func TestShutdown(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("Given a server process that exits cleanly when terminated", t, func() {
+		server := &Server{}
+		runProcess(server, `trap "exit 0" TERM; while true; do sleep 0.1; done`)
+
+		Convey("When Shutdown is called", func() {
+			err := server.Shutdown(testCtx)
+
+			Convey("Then no error is returned and the processes have exited", func() {
+				So(err, ShouldBeNil)
+				So(server.hasExited(), ShouldBeTrue)
+				So(server.watcherCmd, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a server process that fails when terminated", t, func() {
+		server := &Server{}
+		runProcess(server, `trap "exit 3" TERM; while true; do sleep 0.1; done`)
+
+		Convey("When Shutdown is called", func() {
+			err := server.Shutdown(testCtx)
+
+			Convey("Then the exit status is reported", func() {
+				So(err, ShouldBeError)
+				So(err.Error(), ShouldContainSubstring, "did not exit cleanly: exit status 3")
+				So(server.hasExited(), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given a server process that ignores termination", t, func() {
+		server := &Server{}
+		runProcess(server, `trap "" TERM; while true; do sleep 0.1; done`)
+
+		Convey("When Shutdown is called with a deadline", func() {
+			ctx, cancel := context.WithTimeout(testCtx, 300*time.Millisecond)
+			defer cancel()
+			err := server.Shutdown(ctx)
+
+			Convey("Then the process is killed and the timeout is reported", func() {
+				So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+				So(server.hasExited(), ShouldBeTrue)
+				So(server.watcherCmd, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a server with a temporary database directory", t, func() {
+		dbDir, err := os.MkdirTemp("", "")
+		So(err, ShouldBeNil)
+		server := &Server{dbDir: dbDir, ownsDbDir: true}
+		runProcess(server, `trap "exit 0" TERM; while true; do sleep 0.1; done`)
+
+		Convey("When Shutdown is called", func() {
+			So(server.Shutdown(testCtx), ShouldBeNil)
+
+			Convey("Then the database directory is removed", func() {
+				_, err := os.Stat(dbDir)
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -10,15 +10,17 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ONSdigital/dp-mongodb-in-memory/download"
 	"github.com/ONSdigital/dp-mongodb-in-memory/monitor"
 	"github.com/ONSdigital/log.go/v2/log"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -26,14 +28,69 @@ import (
 // max time allowed for mongo to start
 const timeout = 5 * time.Second
 
+// max time allowed to select the server when running admin commands against it
+const serverSelectionTimeout = 2 * time.Second
+
+// Storage engines supported by mongod
+const (
+	StorageEngineEphemeralForTest = "ephemeralForTest"
+	StorageEngineWiredTiger       = "wiredTiger"
+)
+
+// defaultCacheSizeGB is the wiredTiger cache size used if none is given: the minimum mongod accepts
+const defaultCacheSizeGB = 0.25
+
+// MongodPathEnv is the environment variable pointing at an existing mongod binary to use instead of downloading one
+const MongodPathEnv = "MIM_MONGOD_PATH"
+
+// ramDiskDir is a RAM-backed location for the database directory of wiredTiger servers on Linux
+// We define it as a package var so we can override it in tests
+var ramDiskDir = "/dev/shm"
+
+// minRamDiskFree is the free space needed in the RAM-backed location to create database directories in it.
+// It is often small in containers (64MB by default with Docker), which is not enough for the wiredTiger journal
+var minRamDiskFree uint64 = 1 << 30
+
+// freeSpace returns the space available to unprivileged users in the file system holding the given directory
+// We define it as a package var so we can override it in tests
+var freeSpace = diskFree
+
 // Server represents a running MongoDB server.
 type Server struct {
 	cmd            *exec.Cmd
 	watcherCmd     *exec.Cmd
 	dbDir          string
+	ownsDbDir      bool
+	binPath        string
 	port           int
 	replSet        string
 	minMongoLogLvl MongodLogLvl
+	version        *download.Version
+	storageEngine  string
+	cacheSizeGB    float64
+	logf           func(format string, args ...interface{})
+	mongodPath     string
+	offline        bool
+	tools          []string
+	toolsVersion   string
+	// toolsDir is the directory holding the MongoDB Database Tools, if installed with WithDatabaseTools
+	toolsDir string
+	// exited is closed once the process has exited and been reaped
+	exited chan struct{}
+	// exitErr is the error returned by the process once it has exited
+	exitErr error
+	// initiated is set once the server has been initiated as a single member replica set
+	initiated bool
+	// client is the admin client used by the server's own operations, see adminClient
+	client *mongo.Client
+	// failPoints is the set of failpoints enabled by ConfigureFailPoint
+	failPoints map[string]bool
+	mu         sync.Mutex
+	// clusterRole is "configsvr" or "shardsvr" for members of a sharded cluster
+	clusterRole string
+	// configDB is the config server replica set a mongos router connects to.
+	// It is only set for mongos routers, which have no database directory
+	configDB string
 }
 
 type MongodLogLvl int
@@ -62,24 +119,54 @@ func StartWithReplicaSet(ctx context.Context, version, replicaSetName string) (*
 }
 
 // ServerOption defines the template function for defining options that may be used to configure the server
-// The options available are given by the exported variables: WithPort, WithReplicaSet, WithDatabaseDir
+// The options available are given by the exported variables: WithPort, WithReplicaSet, WithDatabaseDir,
+// WithStorageEngine, WithCacheSizeGB, WithLogf, WithMongodPath, WithOffline, WithTools, WithDatabaseTools
 type ServerOption func(*Server)
 
 var (
-	WithReplicaSet  = func(n string) ServerOption { return func(s *Server) { s.replSet = n } }
-	WithPort        = func(p int) ServerOption { return func(s *Server) { s.port = p } }
-	WithDatabaseDir = func(d string) ServerOption { return func(s *Server) { s.dbDir = d } }
+	WithReplicaSet    = func(n string) ServerOption { return func(s *Server) { s.replSet = n } }
+	WithPort          = func(p int) ServerOption { return func(s *Server) { s.port = p } }
+	WithDatabaseDir   = func(d string) ServerOption { return func(s *Server) { s.dbDir = d } }
+	WithStorageEngine = func(e string) ServerOption { return func(s *Server) { s.storageEngine = e } }
+	WithCacheSizeGB   = func(gb float64) ServerOption { return func(s *Server) { s.cacheSizeGB = gb } }
+	WithLogf          = func(f func(format string, args ...interface{})) ServerOption {
+		return func(s *Server) { s.logf = f }
+	}
+	WithMongodPath = func(p string) ServerOption { return func(s *Server) { s.mongodPath = p } }
+	WithOffline    = func(o bool) ServerOption { return func(s *Server) { s.offline = o } }
+	WithTools      = func(names ...string) ServerOption {
+		return func(s *Server) { s.tools = append(s.tools, names...) }
+	}
+	WithDatabaseTools = func(version string) ServerOption { return func(s *Server) { s.toolsVersion = version } }
 )
 
 // StartWithOptions runs a MongoDB server of the given version, with 0 or more options as defined:
-// WithReplicaSet, WithPort, WithDatabaseDir
+// WithReplicaSet, WithPort, WithDatabaseDir, WithStorageEngine, WithCacheSizeGB, WithLogf, WithMongodPath, WithOffline,
+// WithTools, WithDatabaseTools
 //
 // If an empty string is provided in WithReplicaSet, the server is started as a standalone server
 // If a port value of 0 is provided in WithPort, the server is started on a random port
-// If an empty string is provided in WithDatabaseDir, the server is started with a random temporary directory
+// If an empty string is provided in WithDatabaseDir, the server is started with a random temporary directory.
+// For the wiredTiger storage engine on Linux, the temporary directory is created in /dev/shm if available
+// If an empty string is provided in WithStorageEngine, ephemeralForTest is used for standalone servers
+// on versions before 7.0 (where it was removed), and wiredTiger otherwise
+// If a value of 0 is provided in WithCacheSizeGB, wiredTiger uses a cache of 0.25GB
+// If a function is provided in WithLogf, the messages logged by mongod are relayed to it instead of our logger
+// If a path is provided in WithMongodPath (or in the MIM_MONGOD_PATH environment variable), that mongod binary
+// is used and nothing is downloaded. The binary is expected to be of the given version
+// The version may be a partial version (e.g. "7.0"), "latest", "latest-lts" or a range (e.g. ">=6.0 <7.0"),
+// as described by download.ResolveVersion. The concrete version it resolves to is given by Server.Version
+// If true is provided in WithOffline, nothing is downloaded and a *download.NotCachedError is returned
+// if the version is not in the cache
+// The tools given by WithTools are installed along with mongod, and found with Server.ToolPath: "mongos", the legacy
+// "mongo" shell shipped up to MongoDB 5.0, and "mongosh" (download.DefaultMongoshVersion, from its own archive).
+// The other names are rejected with a *download.UnsupportedToolError
+// If a version is provided in WithDatabaseTools (e.g. "100.9" or "latest", as described by download.NewToolsConfig),
+// the MongoDB Database Tools (mongodump, mongorestore...) of that version are installed, and found with Server.ToolPath
+//
+// In replica set mode the server is returned once it has been elected primary and accepts writes.
+// The election is bounded by the context deadline, or by a default timeout if the context has none
 func StartWithOptions(ctx context.Context, version string, so ...ServerOption) (*Server, error) {
-	var err error
-
 	server := &Server{
 		minMongoLogLvl: LogDebug,
 	}
@@ -87,118 +174,223 @@ func StartWithOptions(ctx context.Context, version string, so ...ServerOption) (
 		o(server)
 	}
 
-	if server.port == 0 {
-		server.port, err = getFreeMongoPort()
-		if err != nil {
-			log.Fatal(ctx, "Could not get a free port for the mongo server", err)
+	var opts []download.ConfigOption
+	if server.offline {
+		opts = append(opts, download.WithOffline(true))
+	}
+	if len(server.tools) > 0 {
+		opts = append(opts, download.WithTools(server.tools...))
+	}
+
+	binPath, resolved, err := getOrDownloadBinPath(ctx, version, server.mongodPath, opts...)
+	if err != nil {
+		log.Fatal(ctx, "Could not find mongodb", err)
+		return nil, err
+	}
+
+	server.version = resolved
+
+	if server.toolsVersion != "" {
+		if server.toolsDir, err = getOrDownloadToolsDir(ctx, server.toolsVersion, opts...); err != nil {
 			return nil, err
 		}
 	}
 
-	if server.dbDir == "" {
-		server.dbDir, err = os.MkdirTemp("", "")
-		if err != nil {
-			log.Fatal(ctx, "Error creating data directory", err)
+	if err = server.start(ctx, binPath); err != nil {
+		server.Stop(ctx)
+		return nil, err
+	}
+
+	// Initialise the server as a replica set and wait for it to become primary
+	if server.replSet != "" {
+		members := []*Server{server}
+		if err = initiateReplicaSet(ctx, server.replSet, members); err != nil {
+			log.Error(ctx, "Could not initiate the replica set", err, log.Data{"replicaSet": server.replSet})
+			server.Stop(ctx)
+			return nil, err
+		}
+		server.initiated = true
+		if _, err = waitForPrimary(ctx, server.replSet, members); err != nil {
+			log.Error(ctx, "No primary elected in the replica set", err, log.Data{"replicaSet": server.replSet})
+			server.Stop(ctx)
 			return nil, err
 		}
 	}
 
-	binPath, err := getOrDownloadBinPath(ctx, version)
-	if err != nil {
-		log.Fatal(ctx, "Could not find mongodb", err)
-		return nil, err
+	log.Info(ctx, fmt.Sprintf("mongod started up with the following configuration: %s", server))
+
+	return server, nil
+}
+
+// start runs the mongod (or mongos) binary found at binPath with the server's configuration,
+// together with a watcher process, and waits for it to accept connections.
+// A free port and a temporary database directory are allocated if none were given.
+// If the server fails to start, its processes are killed but its database directory is kept
+func (s *Server) start(ctx context.Context, binPath string) error {
+	var err error
+
+	s.binPath = binPath
+
+	if s.port == 0 {
+		s.port, err = getFreeMongoPort()
+		if err != nil {
+			log.Fatal(ctx, "Could not get a free port for the mongo server", err)
+			return err
+		}
 	}
 
-	log.Info(ctx, "Starting mongod server", log.Data{"binPath": binPath, "dbDir": server.dbDir})
+	if s.storageEngine == "" && !s.isRouter() {
+		s.storageEngine = s.defaultStorageEngine()
+	}
 
-	args := []string{"--bind_ip", "localhost", "--port", strconv.Itoa(server.port), "--dbpath", server.dbDir}
-	switch server.replSet {
-	case "":
-		args = append(args, "--storageEngine", "ephemeralForTest")
-	default:
-		args = append(args, "--storageEngine", "wiredTiger", "--replSet", server.replSet)
+	if s.dbDir == "" && !s.isRouter() {
+		s.dbDir, err = os.MkdirTemp(s.tempDirRoot(), "")
+		if err != nil {
+			log.Fatal(ctx, "Error creating data directory", err)
+			return err
+		}
+		s.ownsDbDir = true
 	}
 
-	server.cmd = exec.Command(binPath, args...)
+	log.Info(ctx, "Starting "+s.processName()+" server", log.Data{"binPath": binPath, "dbDir": s.dbDir})
+
+	args := s.args()
+	s.cmd = exec.Command(binPath, args...)
 
-	startupErrCh := make(chan error)
-	startupPortCh := make(chan int)
-	stdHandler := server.getStdHandler(ctx, startupPortCh, startupErrCh)
-	server.cmd.Stdout = stdHandler
-	server.cmd.Stderr = stdHandler
+	startupErrCh := make(chan error, 1)
+	startupPortCh := make(chan int, 1)
+	stdHandler := s.getStdHandler(ctx, startupPortCh, startupErrCh)
+	s.cmd.Stdout = stdHandler
+	s.cmd.Stderr = stdHandler
 
 	// Run the server
-	err = server.cmd.Start()
+	err = s.cmd.Start()
 	if err != nil {
 		log.Fatal(ctx, "Could not start mongodb", err)
-		server.Stop(ctx)
-		return nil, err
+		_ = stdHandler.Close()
+		return err
 	}
 
+	// Reap the process as soon as it exits, and stop relaying its output
+	exited := make(chan struct{})
+	s.exited = exited
+	go func(cmd *exec.Cmd) {
+		s.exitErr = cmd.Wait()
+		_ = stdHandler.Close()
+		close(exited)
+	}(s.cmd)
+
 	log.Info(ctx, "Starting watcher")
 	// Start a watcher: the watcher is a subprocess that ensures if this process
 	// dies, the mongo server will be killed (and not reparented under init)
-	server.watcherCmd, err = monitor.Run(os.Getpid(), server.cmd.Process.Pid)
+	s.watcherCmd, err = monitor.Run(os.Getpid(), s.cmd.Process.Pid)
 	if err != nil {
 		log.Error(ctx, "Could not start watcher", err)
-		server.Stop(ctx)
-		return nil, err
+		_ = s.Kill(ctx)
+		return err
 	}
 
 	delay := time.NewTimer(timeout)
 	select {
-	case server.port = <-startupPortCh:
+	case s.port = <-startupPortCh:
 	case err := <-startupErrCh:
 		// Ensure timer is stopped and its resources are freed
 		if !delay.Stop() {
 			// if the timer has been stopped then read from the channel
 			<-delay.C
 		}
-		server.Stop(ctx)
-		return nil, err
+		_ = s.Kill(ctx)
+		return err
 	case <-delay.C:
-		server.Stop(ctx)
-		return nil, errors.New("timed out waiting for mongod to start")
+		_ = s.Kill(ctx)
+		return errors.New("timed out waiting for " + s.processName() + " to start")
 	}
 
-	// Initialise the server as a replica set
-	if server.replSet != "" {
-		c, err := mongo.Connect(ctx, options.Client().ApplyURI(server.URI()+"/admin?directConnection=true"))
-		if err != nil {
-			return nil, err
-		}
-		replSetConfig := fmt.Sprintf(`{"_id": %s, "members": [{"_id": 0, "host": "localhost:%d"}]}`, server.ReplicaSet(), server.Port())
-		res := c.Database("admin").RunCommand(ctx, bson.D{{"replSetInitiate", replSetConfig}})
-		if err = res.Err(); err != nil {
-			return nil, err
+	return nil
+}
+
+// args returns the command line arguments for the server's configuration
+func (s *Server) args() []string {
+	args := []string{"--bind_ip", "localhost", "--port", strconv.Itoa(s.port)}
+	if s.isRouter() {
+		return append(args, "--configdb", s.configDB)
+	}
+
+	args = append(args, "--dbpath", s.dbDir, "--storageEngine", s.storageEngine)
+	if s.replSet != "" {
+		args = append(args, "--replSet", s.replSet)
+	}
+
+	if s.clusterRole != "" {
+		args = append(args, "--"+s.clusterRole)
+	}
+
+	if s.storageEngine == StorageEngineWiredTiger {
+		cacheSizeGB := s.cacheSizeGB
+		if cacheSizeGB == 0 {
+			cacheSizeGB = defaultCacheSizeGB
 		}
+		args = append(args, "--wiredTigerCacheSizeGB", strconv.FormatFloat(cacheSizeGB, 'f', -1, 64))
 	}
 
-	log.Info(ctx, fmt.Sprintf("mongod started up with the following configuration: %s", server))
+	// Allow failpoints to be configured
+	args = append(args, "--setParameter", "enableTestCommands=1")
 
-	return server, nil
+	return args
+}
+
+// defaultStorageEngine returns the storage engine to use if none was given.
+// ephemeralForTest is only used for standalone servers, and is not available from version 7.0
+// (including its release candidates)
+func (s *Server) defaultStorageEngine() string {
+	if s.replSet == "" && (s.version == nil || s.version.Major < 7) {
+		return StorageEngineEphemeralForTest
+	}
+	return StorageEngineWiredTiger
+}
+
+// tempDirRoot returns the directory where a temporary database directory should be created.
+// The wiredTiger storage engine uses the disk, so a RAM-backed location is preferred if available
+// and it has enough free space (see minRamDiskFree)
+func (s *Server) tempDirRoot() string {
+	if s.storageEngine != StorageEngineWiredTiger || runtime.GOOS != "linux" {
+		return ""
+	}
+	if info, err := os.Stat(ramDiskDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	if free, err := freeSpace(ramDiskDir); err != nil || free < minRamDiskFree {
+		return ""
+	}
+	return ramDiskDir
+}
+
+// isRouter checks whether the server is a mongos router
+func (s *Server) isRouter() bool {
+	return s.configDB != ""
+}
+
+// processName returns the name of the binary the server runs
+func (s *Server) processName() string {
+	if s.isRouter() {
+		return "mongos"
+	}
+	return "mongod"
 }
 
 // Stop kills the mongo server.
+// The database directory is removed, unless it was given with WithDatabaseDir
 func (s *Server) Stop(ctx context.Context) {
-	if s.cmd != nil {
-		err := s.cmd.Process.Kill()
-		if err != nil {
-			log.Error(ctx, "Error stopping mongod process", err, log.Data{"pid": s.cmd.Process.Pid})
-		}
+	if err := s.Kill(ctx); err != nil {
+		log.Error(ctx, "Error stopping mongod process", err)
 	}
 
-	if s.watcherCmd != nil {
-		err := s.watcherCmd.Process.Kill()
+	if s.ownsDbDir {
+		err := os.RemoveAll(s.dbDir)
 		if err != nil {
-			log.Error(ctx, "error stopping watcher process", err, log.Data{"pid": s.watcherCmd.Process.Pid})
+			log.Error(ctx, "Error removing data directory", err, log.Data{"dir": s.dbDir})
 		}
 	}
-
-	err := os.RemoveAll(s.dbDir)
-	if err != nil {
-		log.Error(ctx, "Error removing data directory", err, log.Data{"dir": s.dbDir})
-	}
 }
 
 // Port returns the port the server is listening on.
@@ -211,6 +403,15 @@ func (s *Server) URI() string {
 	return fmt.Sprintf("mongodb://localhost:%d", s.port)
 }
 
+// directClient returns a client connected directly to the server's admin database,
+// regardless of its replica set membership
+func (s *Server) directClient(ctx context.Context) (*mongo.Client, error) {
+	opts := options.Client().
+		ApplyURI(s.URI() + "/admin?directConnection=true").
+		SetServerSelectionTimeout(serverSelectionTimeout)
+	return mongo.Connect(ctx, opts)
+}
+
 // ReplicaSet returns the Replica Set name being used by the server (cluster of 1)
 func (s *Server) ReplicaSet() string {
 	return s.replSet
@@ -221,10 +422,36 @@ func (s *Server) DBdir() string {
 	return s.dbDir
 }
 
+// ToolPath returns the path to the named executable: one of the MongoDB Database Tools (e.g. "mongorestore")
+// if installed with WithDatabaseTools, or else the one stored alongside the binary the server runs (e.g. "mongos"),
+// which is installed with it if given by WithTools
+func (s *Server) ToolPath(name string) string {
+	if s.toolsDir != "" {
+		if p := filepath.Join(s.toolsDir, name); fileExists(p) {
+			return p
+		}
+	}
+	return toolPath(s.binPath, name)
+}
+
+// StorageEngine returns the storage engine being used by the server
+func (s *Server) StorageEngine() string {
+	return s.storageEngine
+}
+
+// Version returns the MongoDB version of the server, e.g. 7.0.4 when started with version "7.0"
+func (s *Server) Version() string {
+	if s.version == nil {
+		return ""
+	}
+	return s.version.String()
+}
+
 func (s *Server) String() string {
 	buf := new(strings.Builder)
 	_, _ = fmt.Fprintf(buf, "listening on: localhost:%d;", s.port)
 	_, _ = fmt.Fprintf(buf, " using DB directory: %s;", s.dbDir)
+	_, _ = fmt.Fprintf(buf, " using storage engine: %s;", s.storageEngine)
 	if s.replSet != "" {
 		_, _ = fmt.Fprintf(buf, " configured as a cluster with replica set name: %s", s.replSet)
 	}
@@ -236,24 +463,78 @@ func (s *Server) SetMinLogLevel(lvl MongodLogLvl) {
 	s.minMongoLogLvl = lvl
 }
 
-func getOrDownloadBinPath(ctx context.Context, version string) (string, error) {
-	config, err := download.NewConfig(ctx, version)
+// getOrDownloadBinPath returns the path to the mongod binary for the given version,
+// downloading it if it is not in the cache, along with the concrete version the given one resolves to.
+// If mongodPath, or else the MIM_MONGOD_PATH environment variable, is set, that binary is used as is.
+// The download options (e.g. download.WithOffline) are used to resolve the version and get the binary
+func getOrDownloadBinPath(ctx context.Context, version, mongodPath string, opts ...download.ConfigOption) (string, *download.Version, error) {
+	if mongodPath == "" {
+		mongodPath = os.Getenv(MongodPathEnv)
+	}
+	if mongodPath != "" {
+		if _, err := os.Stat(mongodPath); err != nil {
+			log.Error(ctx, "mongod binary not found", err, log.Data{"path": mongodPath})
+			return "", nil, err
+		}
+		resolved, err := download.ResolveVersion(ctx, version, opts...)
+		if err != nil {
+			log.Error(ctx, "Invalid mongodb version", err, log.Data{"version": version})
+			return "", nil, err
+		}
+		log.Info(ctx, "Using the given mongod binary", log.Data{"path": mongodPath, "version": resolved.String()})
+		return mongodPath, resolved, nil
+	}
+
+	config, err := download.NewConfig(ctx, version, opts...)
 	if err != nil {
 		log.Error(ctx, "Failed to create config", err)
-		return "", err
+		return "", nil, err
 	}
 
 	if err := download.GetMongoDB(ctx, *config); err != nil {
+		return "", nil, err
+	}
+	resolved := config.Version()
+	return config.MongoPath(), &resolved, nil
+}
+
+// getOrDownloadToolsDir returns the directory holding the MongoDB Database Tools of the given version,
+// downloading them if they are not in the cache
+func getOrDownloadToolsDir(ctx context.Context, version string, opts ...download.ConfigOption) (string, error) {
+	config, err := download.NewToolsConfig(ctx, version, opts...)
+	if err != nil {
+		log.Error(ctx, "Failed to create config for the MongoDB Database Tools", err, log.Data{"version": version})
+		return "", err
+	}
+
+	if err = download.GetDatabaseTools(ctx, *config); err != nil {
+		log.Error(ctx, "Could not get the MongoDB Database Tools", err, log.Data{"version": version})
 		return "", err
 	}
-	return config.MongoPath(), nil
+	return filepath.Dir(config.ToolPath("mongorestore")), nil
+}
+
+// fileExists reports whether the given file exists
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// mongosPath returns the path to the mongos binary stored alongside the given mongod binary
+func mongosPath(mongodPath string) string {
+	return toolPath(mongodPath, "mongos")
+}
+
+// toolPath returns the path to the named executable stored alongside the given mongod (or mongos) binary
+func toolPath(binPath, name string) string {
+	return filepath.Join(filepath.Dir(binPath), name)
 }
 
 // getStdHandler handler relays messages from stdout/stderr to our logger.
 // It accepts 2 channels:
 // errCh will receive any error logged,
 // okCh will receive the port number if mongodb started successfully
-func (s *Server) getStdHandler(ctx context.Context, okCh chan<- int, errCh chan<- error) io.Writer {
+func (s *Server) getStdHandler(ctx context.Context, okCh chan<- int, errCh chan<- error) io.WriteCloser {
 	reader, writer := io.Pipe()
 
 	go func() {
@@ -266,48 +547,79 @@ func (s *Server) getStdHandler(ctx context.Context, okCh chan<- int, errCh chan<
 			if err != nil {
 				// Output the message as is if not json.
 				// Log to info as unable to extract severity
-				log.Info(ctx, fmt.Sprintf("[mongod] %s", text))
+				s.relayLog(ctx, LogInfo, fmt.Sprintf("[%s] %s", s.processName(), text), nil)
 			} else {
 				message := logMessage["msg"]
 				delete(logMessage, "msg")
-				msg := fmt.Sprintf("[mongod] %s", message)
+				msg := fmt.Sprintf("[%s] %s", s.processName(), message)
 				switch logMessage["s"] { // severity
 				case "E":
 					fallthrough
 				case "F":
 					// error or fatal
-					errCh <- fmt.Errorf("mongod startup failed: %s", message)
-					log.Error(ctx, msg, nil, logMessage)
+					// only the first error is relevant to the startup, and no one listens after it
+					select {
+					case errCh <- fmt.Errorf("%s startup failed: %s", s.processName(), message):
+					default:
+					}
+					s.relayLog(ctx, LogError, msg, logMessage)
 				case "W":
 					if s.minMongoLogLvl >= LogWarn {
-						log.Warn(ctx, msg, logMessage)
+						s.relayLog(ctx, LogWarn, msg, logMessage)
 					}
 				case "I":
 					if message == "Waiting for connections" {
 						// Mongo running successfully: find port
 						attr := logMessage["attr"].(map[string]interface{})
-						okCh <- int(attr["port"].(float64))
+						select {
+						case okCh <- int(attr["port"].(float64)):
+						default:
+						}
 					}
 					if s.minMongoLogLvl >= LogInfo {
-						log.Info(ctx, msg, logMessage)
+						s.relayLog(ctx, LogInfo, msg, logMessage)
 					}
 				case "D":
 				default:
 					if s.minMongoLogLvl >= LogDebug {
-						log.Info(ctx, msg, logMessage)
+						s.relayLog(ctx, LogDebug, msg, logMessage)
 					}
 				}
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			log.Error(ctx, "reading mongod stdout/stderr failed: %s", err)
+			log.Error(ctx, "reading "+s.processName()+" stdout/stderr failed", err)
 		}
 	}()
 
 	return writer
 }
 
+// relayLog relays a message logged by the server process to the log function given by WithLogf if any,
+// or to our logger otherwise
+func (s *Server) relayLog(ctx context.Context, lvl MongodLogLvl, msg string, data log.Data) {
+	if s.logf != nil {
+		if len(data) == 0 {
+			s.logf("%s", msg)
+		} else {
+			s.logf("%s %v", msg, data)
+		}
+		return
+	}
+
+	switch {
+	case lvl == LogError:
+		log.Error(ctx, msg, nil, data)
+	case lvl == LogWarn:
+		log.Warn(ctx, msg, data)
+	case len(data) == 0:
+		log.Info(ctx, msg)
+	default:
+		log.Info(ctx, msg, data)
+	}
+}
+
 // getFreeMongoPort is simple utility to find a free port on the "localhost" interface of the host machine
 // for a local mongo server to use
 func getFreeMongoPort() (port int, err error) {
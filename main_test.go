@@ -2,14 +2,19 @@ package mim
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"runtime"
 	"strconv"
 	"testing"
 
+	"github.com/ONSdigital/dp-mongodb-in-memory/download"
+
 	. "github.com/smartystreets/goconvey/convey"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -206,6 +211,13 @@ func TestStart_All(t *testing.T) {
 								So(client.Ping(testCtx, nil), ShouldBeNil)
 							})
 
+							Convey("And the server accepts writes straight away", func() {
+								client, err := mongo.Connect(testCtx, options.Client().ApplyURI(server.URI()).SetReplicaSet(server.ReplicaSet()))
+								So(err, ShouldBeNil)
+								_, err = client.Database("test").Collection("test").InsertOne(testCtx, bson.D{{Key: "a", Value: 1}})
+								So(err, ShouldBeNil)
+							})
+
 						})
 					})
 				})
@@ -258,11 +270,36 @@ func TestStart_All(t *testing.T) {
 				})
 			})
 
+			Convey("When the StartWithOptions method is called with the wiredTiger storage engine option", func() {
+				server, err := StartWithOptions(testCtx, version, WithStorageEngine(StorageEngineWiredTiger), WithCacheSizeGB(0.5))
+				defer server.Stop(testCtx)
+
+				Convey("Then no error is returned", func() {
+					So(err, ShouldBeNil)
+					Convey("And the mongod process has run as a standalone server with the wiredTiger storage engine", func() {
+						So(server, ShouldNotBeNil)
+						So(server.StorageEngine(), ShouldEqual, StorageEngineWiredTiger)
+						So(server.cmd.Args[7], ShouldEqual, "--storageEngine")
+						So(server.cmd.Args[8], ShouldEqual, "wiredTiger")
+						So(server.cmd.Args[9], ShouldEqual, "--wiredTigerCacheSizeGB")
+						So(server.cmd.Args[10], ShouldEqual, "0.5")
+
+						Convey("And the server accepts connections", func() {
+							client, err := mongo.Connect(testCtx, options.Client().ApplyURI(server.URI()))
+							So(err, ShouldBeNil)
+							So(client, ShouldNotBeNil)
+							So(client.Ping(testCtx, nil), ShouldBeNil)
+						})
+					})
+				})
+			})
+
 			Convey("When the StartWithOptions method is called with the port and database directory options", func() {
 				tempDir, err := os.MkdirTemp("", "")
 				if err != nil {
 					t.Fatalf("Error creating data directory: %v", err)
 				}
+				defer os.RemoveAll(tempDir)
 				server, err := StartWithOptions(testCtx, version, WithPort(27017), WithDatabaseDir(tempDir))
 				defer server.Stop(testCtx)
 
@@ -311,6 +348,113 @@ func TestStart_All(t *testing.T) {
 	}
 }
 
+func TestStorageEngine(t *testing.T) {
+	Convey("Given a standalone server", t, func() {
+		server := &Server{port: 27017, dbDir: "/tmp/db"}
+
+		Convey("When the version is before 7.0", func() {
+			server.version = &download.Version{Major: 6, Minor: 0, Patch: 9}
+
+			Convey("Then the ephemeralForTest storage engine is used by default", func() {
+				So(server.defaultStorageEngine(), ShouldEqual, StorageEngineEphemeralForTest)
+			})
+		})
+
+		Convey("When the version is 7.0 or later", func() {
+			server.version = &download.Version{Major: 7, Minor: 0, Patch: 2}
+
+			Convey("Then the wiredTiger storage engine is used by default", func() {
+				So(server.defaultStorageEngine(), ShouldEqual, StorageEngineWiredTiger)
+			})
+		})
+
+		Convey("When the version is a 7.0 release candidate", func() {
+			server.version = &download.Version{Major: 7, Minor: 0, Patch: 0, Prerelease: "rc1"}
+
+			Convey("Then the wiredTiger storage engine is used by default", func() {
+				So(server.defaultStorageEngine(), ShouldEqual, StorageEngineWiredTiger)
+			})
+		})
+
+		Convey("When the wiredTiger storage engine is used with a cache size", func() {
+			server.storageEngine = StorageEngineWiredTiger
+			server.cacheSizeGB = 1.5
+
+			Convey("Then the cache size is passed to mongod", func() {
+				So(server.args(), ShouldResemble, []string{
+					"--bind_ip", "localhost", "--port", "27017", "--dbpath", "/tmp/db",
+					"--storageEngine", "wiredTiger", "--wiredTigerCacheSizeGB", "1.5",
+					"--setParameter", "enableTestCommands=1",
+				})
+			})
+		})
+
+		Convey("When the ephemeralForTest storage engine is used", func() {
+			server.storageEngine = StorageEngineEphemeralForTest
+
+			Convey("Then no cache size is passed to mongod", func() {
+				So(server.args(), ShouldResemble, []string{
+					"--bind_ip", "localhost", "--port", "27017", "--dbpath", "/tmp/db",
+					"--storageEngine", "ephemeralForTest",
+					"--setParameter", "enableTestCommands=1",
+				})
+			})
+
+			Convey("Then the database directory is created in the default temporary directory", func() {
+				So(server.tempDirRoot(), ShouldBeBlank)
+			})
+		})
+	})
+
+	Convey("Given a replica set server of a version before 7.0", t, func() {
+		server := &Server{replSet: "rs0", version: &download.Version{Major: 5, Minor: 0, Patch: 2}}
+
+		Convey("Then the wiredTiger storage engine is used by default", func() {
+			So(server.defaultStorageEngine(), ShouldEqual, StorageEngineWiredTiger)
+		})
+	})
+
+	Convey("Given a wiredTiger server on Linux", t, func() {
+		if runtime.GOOS != "linux" {
+			t.Skip("RAM-backed directories are only used on Linux")
+		}
+		originalRamDiskDir := ramDiskDir
+		originalFreeSpace := freeSpace
+		server := &Server{storageEngine: StorageEngineWiredTiger}
+
+		Convey("When a RAM-backed directory is available", func() {
+			ramDiskDir = t.TempDir()
+			freeSpace = func(string) (uint64, error) { return minRamDiskFree, nil }
+
+			Convey("Then the database directory is created in it", func() {
+				So(server.tempDirRoot(), ShouldEqual, ramDiskDir)
+			})
+		})
+
+		Convey("When the RAM-backed directory is too small, as /dev/shm in Docker containers", func() {
+			ramDiskDir = t.TempDir()
+			freeSpace = func(string) (uint64, error) { return 64 << 20, nil }
+
+			Convey("Then the database directory is created in the default temporary directory", func() {
+				So(server.tempDirRoot(), ShouldBeBlank)
+			})
+		})
+
+		Convey("When no RAM-backed directory is available", func() {
+			ramDiskDir = "/does/not/exist"
+
+			Convey("Then the database directory is created in the default temporary directory", func() {
+				So(server.tempDirRoot(), ShouldBeBlank)
+			})
+		})
+
+		Reset(func() {
+			ramDiskDir = originalRamDiskDir
+			freeSpace = originalFreeSpace
+		})
+	})
+}
+
 func TestGetFreeMongoPort(t *testing.T) {
 	Convey("When getFreeMongoPort() is called n times, where n > 1", t, func() {
 		n := 10
@@ -329,3 +473,70 @@ func TestGetFreeMongoPort(t *testing.T) {
 		})
 	})
 }
+
+func TestGetOrDownloadBinPath(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("Given an existing mongod binary", t, func() {
+		binDir := t.TempDir()
+		binPath := binDir + "/mongod"
+		So(os.WriteFile(binPath, []byte("mongod"), 0755), ShouldBeNil)
+
+		Convey("When its path is given", func() {
+			path, version, err := getOrDownloadBinPath(testCtx, "5.0.2", binPath)
+
+			Convey("Then it is used without downloading anything", func() {
+				So(err, ShouldBeNil)
+				So(path, ShouldEqual, binPath)
+				So(version.String(), ShouldEqual, "5.0.2")
+			})
+
+			Convey("Then the tools are looked up alongside it", func() {
+				server := &Server{binPath: path}
+				So(server.ToolPath("mongo"), ShouldEqual, binDir+"/mongo")
+			})
+
+			Convey("Then the database tools are looked up in their own directory if installed", func() {
+				toolsDir := t.TempDir()
+				So(os.WriteFile(toolsDir+"/mongorestore", []byte("mongorestore"), 0755), ShouldBeNil)
+				server := &Server{binPath: path, toolsDir: toolsDir}
+				So(server.ToolPath("mongorestore"), ShouldEqual, toolsDir+"/mongorestore")
+				So(server.ToolPath("mongos"), ShouldEqual, binDir+"/mongos")
+			})
+		})
+
+		Convey("When its path is set in the MIM_MONGOD_PATH environment variable", func() {
+			t.Setenv(MongodPathEnv, binPath)
+			path, _, err := getOrDownloadBinPath(testCtx, "5.0.2", "")
+
+			Convey("Then it is used without downloading anything", func() {
+				So(err, ShouldBeNil)
+				So(path, ShouldEqual, binPath)
+			})
+		})
+
+		Convey("When a path that does not exist is given", func() {
+			_, _, err := getOrDownloadBinPath(testCtx, "5.0.2", binDir+"/missing")
+
+			Convey("Then an error is returned", func() {
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given the offline mode and an empty cache", t, func() {
+		t.Setenv(MongodPathEnv, "")
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		Convey("When the binary path is requested", func() {
+			_, _, err := getOrDownloadBinPath(testCtx, "5.0.2", "", download.WithOffline(true))
+
+			Convey("Then a NotCachedError is returned", func() {
+				var notCached *download.NotCachedError
+				So(errors.As(err, &notCached), ShouldBeTrue)
+				So(notCached.Version, ShouldEqual, "5.0.2")
+				So(notCached.Available, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,62 @@
+// Package mimtest provides helpers to run in-memory MongoDB servers from Go tests.
+package mimtest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	mim "github.com/ONSdigital/dp-mongodb-in-memory"
+)
+
+// StartT runs a MongoDB server of the given version, with 0 or more options as accepted by mim.StartWithOptions.
+// The test fails straight away if the server can not be started, and the server is shut down when the test
+// and its subtests complete. The test fails if the server does not shut down cleanly.
+// The messages logged by mongod are relayed to t.Log, so they are only shown for failing tests
+// (or when running go test -v), unless a mim.WithLogf option is given
+func StartT(t testing.TB, version string, opts ...mim.ServerOption) *mim.Server {
+	t.Helper()
+	ctx := context.Background()
+
+	// Registered first so that it runs last, once the server has been stopped
+	logger := &testLogger{t: t}
+	t.Cleanup(logger.stop)
+
+	opts = append([]mim.ServerOption{mim.WithLogf(logger.logf)}, opts...)
+	server, err := mim.StartWithOptions(ctx, version, opts...)
+	if err != nil {
+		t.Fatalf("could not start mongod %s: %v", version, err)
+	}
+	t.Cleanup(func() {
+		if err := server.Shutdown(ctx); err != nil {
+			t.Errorf("could not shut down mongod: %v", err)
+		}
+	})
+
+	return server
+}
+
+// testLogger relays messages to a test log until the test completes.
+// Logging to a test once it has completed panics, and mongod may still
+// log messages while it is shutting down
+type testLogger struct {
+	t       testing.TB
+	mu      sync.Mutex
+	stopped bool
+}
+
+func (l *testLogger) logf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.stopped {
+		l.t.Logf(format, args...)
+	}
+}
+
+func (l *testLogger) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stopped = true
+}
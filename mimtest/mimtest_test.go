@@ -0,0 +1,62 @@
+package mimtest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestStartT(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("When StartT is called", t, func() {
+		server := StartT(t, "5.0.2")
+
+		Convey("Then a running server is returned", func() {
+			So(server, ShouldNotBeNil)
+
+			client, err := mongo.Connect(testCtx, options.Client().ApplyURI(server.URI()))
+			So(err, ShouldBeNil)
+			So(client.Ping(testCtx, nil), ShouldBeNil)
+		})
+	})
+}
+
+// recorder is a testing.TB that records the messages logged
+type recorder struct {
+	testing.TB
+	messages []string
+}
+
+func (r *recorder) Logf(format string, args ...interface{}) {
+	r.messages = append(r.messages, fmt.Sprintf(format, args...))
+}
+
+func TestTestLogger(t *testing.T) {
+	Convey("Given a test logger", t, func() {
+		rec := &recorder{}
+		logger := &testLogger{t: rec}
+
+		Convey("When a message is logged before the test completes", func() {
+			logger.logf("[mongod] %s", "Waiting for connections")
+
+			Convey("Then it is relayed to the test log", func() {
+				So(rec.messages, ShouldResemble, []string{"[mongod] Waiting for connections"})
+			})
+		})
+
+		Convey("When a message is logged after the test completes", func() {
+			logger.stop()
+			logger.logf("[mongod] %s", "Received signal")
+
+			Convey("Then it is dropped", func() {
+				So(rec.messages, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package mimtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	mim "github.com/ONSdigital/dp-mongodb-in-memory"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxDatabaseNameLength is the maximum length of a MongoDB database name
+const maxDatabaseNameLength = 63
+
+// invalidDatabaseNameChars matches the characters we do not use in database names
+var invalidDatabaseNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// shared holds the server shared by all the tests of a package, and a client connected to it
+var shared struct {
+	mu      sync.RWMutex
+	server  *mim.Server
+	client  *mongo.Client
+	counter atomic.Uint64
+}
+
+// Run starts a MongoDB server of the given version, with 0 or more options as accepted by mim.StartWithOptions,
+// to be shared by all the tests of a package. It then runs the tests and stops the server once they have completed.
+// It is meant to be called from TestMain, and returns the exit code to pass to os.Exit:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(mimtest.Run(m, "5.0.2"))
+//	}
+func Run(m *testing.M, version string, opts ...mim.ServerOption) int {
+	ctx := context.Background()
+
+	if err := startShared(ctx, version, opts...); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "could not start shared mongod %s: %v\n", version, err)
+		return 1
+	}
+	defer stopShared(ctx)
+
+	return m.Run()
+}
+
+// Server returns the server shared by all the tests of the package, as started by Run
+func Server() *mim.Server {
+	shared.mu.RLock()
+	defer shared.mu.RUnlock()
+
+	return shared.server
+}
+
+// Database returns a database of the shared server started by Run, for the exclusive use of the test.
+// The database is dropped when the test and its subtests complete, so that tests running in parallel
+// can share the server safely
+func Database(t testing.TB) *mongo.Database {
+	t.Helper()
+
+	shared.mu.RLock()
+	client := shared.client
+	shared.mu.RUnlock()
+
+	if client == nil {
+		t.Fatal("no shared mongod server: mimtest.Run must be called from TestMain")
+	}
+
+	db := client.Database(DatabaseName(t))
+	t.Cleanup(func() {
+		if err := db.Drop(context.Background()); err != nil {
+			t.Errorf("could not drop database %s: %v", db.Name(), err)
+		}
+	})
+
+	return db
+}
+
+// DatabaseName returns a unique, valid, database name for the test, based on its name
+func DatabaseName(t testing.TB) string {
+	suffix := fmt.Sprintf("_%d", shared.counter.Add(1))
+	name := invalidDatabaseNameChars.ReplaceAllString(t.Name(), "_")
+	if len(name)+len(suffix) > maxDatabaseNameLength {
+		name = name[:maxDatabaseNameLength-len(suffix)]
+	}
+
+	return name + suffix
+}
+
+// startShared starts the shared server and connects a client to it
+func startShared(ctx context.Context, version string, opts ...mim.ServerOption) error {
+	server, err := mim.StartWithOptions(ctx, version, opts...)
+	if err != nil {
+		return err
+	}
+
+	clientOpts := options.Client().ApplyURI(server.URI())
+	if server.ReplicaSet() != "" {
+		clientOpts.SetReplicaSet(server.ReplicaSet())
+	}
+	client, err := mongo.Connect(ctx, clientOpts)
+	if err != nil {
+		server.Stop(ctx)
+		return err
+	}
+
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	shared.server = server
+	shared.client = client
+	return nil
+}
+
+// stopShared disconnects the shared client and shuts down the shared server
+func stopShared(ctx context.Context) {
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	if shared.client != nil {
+		_ = shared.client.Disconnect(ctx)
+	}
+	if shared.server != nil {
+		if err := shared.server.Shutdown(ctx); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not shut down shared mongod: %v\n", err)
+		}
+	}
+	shared.server = nil
+	shared.client = nil
+}
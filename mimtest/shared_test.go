@@ -0,0 +1,71 @@
+package mimtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDatabase(t *testing.T) {
+	testCtx := context.Background()
+
+	Convey("Given a shared server", t, func() {
+		So(startShared(testCtx, "5.0.2"), ShouldBeNil)
+		defer stopShared(testCtx)
+
+		Convey("When two tests get a database", func() {
+			var names []string
+			for _, name := range []string{"first", "second"} {
+				t.Run(name, func(t *testing.T) {
+					db := Database(t)
+					names = append(names, db.Name())
+
+					_, err := db.Collection("test").InsertOne(testCtx, bson.D{{Key: "a", Value: 1}})
+					if err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+
+			Convey("Then each test gets its own database", func() {
+				So(names, ShouldHaveLength, 2)
+				So(names[0], ShouldNotEqual, names[1])
+
+				Convey("And the databases are dropped when the tests complete", func() {
+					dbs, err := shared.client.ListDatabaseNames(testCtx, bson.D{})
+					So(err, ShouldBeNil)
+					So(dbs, ShouldNotContain, names[0])
+					So(dbs, ShouldNotContain, names[1])
+				})
+			})
+		})
+	})
+}
+
+func TestDatabaseName(t *testing.T) {
+	Convey("Given a test with characters not valid in a database name", t, func() {
+		t.Run("sub test/with.dots", func(t *testing.T) {
+			name := DatabaseName(t)
+
+			Convey("Then the database name only has valid characters", t, func() {
+				So(name, ShouldStartWith, "TestDatabaseName_sub_test_with_dots_")
+				So(strings.ContainsAny(name, "/. "), ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a test with a long name", t, func() {
+		t.Run(strings.Repeat("a", 100), func(t *testing.T) {
+			first, second := DatabaseName(t), DatabaseName(t)
+
+			Convey("Then the database names are truncated and still unique", t, func() {
+				So(len(first), ShouldBeLessThanOrEqualTo, maxDatabaseNameLength)
+				So(first, ShouldNotEqual, second)
+			})
+		})
+	})
+}
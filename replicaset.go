@@ -0,0 +1,137 @@
+package mim
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// interval between attempts to find the primary member of a replica set
+const primaryPollInterval = 100 * time.Millisecond
+
+// max time allowed for a replica set to elect a primary, if the context has no deadline
+const electionTimeout = 30 * time.Second
+
+// initiateReplicaSet initiates a replica set with the given name, made of the given members.
+// The command is sent to the first member.
+func initiateReplicaSet(ctx context.Context, name string, members []*Server) error {
+	c, err := members[0].directClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.Disconnect(ctx)
+	}()
+
+	res := c.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: replicaSetConfig(name, members)}})
+	return res.Err()
+}
+
+// replicaSetConfig builds the replica set configuration document for the given members
+func replicaSetConfig(name string, members []*Server) bson.D {
+	memberDocs := bson.A{}
+	for i, m := range members {
+		memberDocs = append(memberDocs, bson.D{
+			{Key: "_id", Value: i},
+			{Key: "host", Value: fmt.Sprintf("localhost:%d", m.Port())},
+		})
+	}
+
+	config := bson.D{{Key: "_id", Value: name}}
+	if len(members) > 0 && members[0].clusterRole == "configsvr" {
+		config = append(config, bson.E{Key: "configsvr", Value: true})
+	}
+	return append(config, bson.E{Key: "members", Value: memberDocs})
+}
+
+// waitForPrimary polls the given members until one of them is a writable primary, and returns it.
+// If the context is done first, an *ElectionTimeoutError with the last state seen for each member is returned.
+// If the context has no deadline, the wait is bounded by electionTimeout
+func waitForPrimary(ctx context.Context, name string, members []*Server) (*Server, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, electionTimeout)
+		defer cancel()
+	}
+
+	states := make(map[string]string, len(members))
+	for {
+		for _, m := range members {
+			writable, state := m.replicaState(ctx)
+			if writable {
+				return m, nil
+			}
+			states[fmt.Sprintf("localhost:%d", m.Port())] = state
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, &ElectionTimeoutError{
+				ReplicaSet:   name,
+				MemberStates: states,
+				Err:          ctx.Err(),
+			}
+		case <-time.After(primaryPollInterval):
+		}
+	}
+}
+
+// replicaState reports whether the server is currently a writable primary, through the hello command,
+// and its replica set member state (e.g. "STARTUP2", "SECONDARY", "PRIMARY") as given by replSetGetStatus.
+// If the state can not be determined, the error found is returned as the state
+func (s *Server) replicaState(ctx context.Context) (bool, string) {
+	c, err := s.directClient(ctx)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer func() {
+		_ = c.Disconnect(ctx)
+	}()
+
+	var hello struct {
+		IsWritablePrimary bool `bson:"isWritablePrimary"`
+	}
+	if err = c.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false, err.Error()
+	}
+
+	var status struct {
+		MyState int `bson:"myState"`
+	}
+	if err = c.Database("admin").RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status); err != nil {
+		return hello.IsWritablePrimary, err.Error()
+	}
+
+	return hello.IsWritablePrimary, memberStateName(status.MyState)
+}
+
+// memberStateName returns the name of a replica set member state, as documented in
+// https://www.mongodb.com/docs/manual/reference/replica-states/
+func memberStateName(state int) string {
+	switch state {
+	case 0:
+		return "STARTUP"
+	case 1:
+		return "PRIMARY"
+	case 2:
+		return "SECONDARY"
+	case 3:
+		return "RECOVERING"
+	case 5:
+		return "STARTUP2"
+	case 6:
+		return "UNKNOWN"
+	case 7:
+		return "ARBITER"
+	case 8:
+		return "DOWN"
+	case 9:
+		return "ROLLBACK"
+	case 10:
+		return "REMOVED"
+	default:
+		return fmt.Sprintf("state %d", state)
+	}
+}
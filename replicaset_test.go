@@ -0,0 +1,53 @@
+package mim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWaitForPrimary(t *testing.T) {
+	Convey("Given a replica set member that is not running", t, func() {
+		port, err := getFreeMongoPort()
+		So(err, ShouldBeNil)
+		member := &Server{port: port, replSet: "rs0"}
+
+		Convey("When waitForPrimary is called", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+
+			primary, err := waitForPrimary(ctx, "rs0", []*Server{member})
+
+			Convey("Then an ElectionTimeoutError is returned once the context is done", func() {
+				So(primary, ShouldBeNil)
+
+				var electionErr *ElectionTimeoutError
+				So(errors.As(err, &electionErr), ShouldBeTrue)
+				So(electionErr.ReplicaSet, ShouldEqual, "rs0")
+				So(electionErr.MemberStates, ShouldContainKey, fmt.Sprintf("localhost:%d", port))
+				So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			})
+		})
+	})
+}
+
+func TestElectionTimeoutError(t *testing.T) {
+	Convey("Given an ElectionTimeoutError", t, func() {
+		err := &ElectionTimeoutError{
+			ReplicaSet: "rs0",
+			MemberStates: map[string]string{
+				"localhost:27018": "SECONDARY",
+				"localhost:27017": "STARTUP2",
+			},
+			Err: context.DeadlineExceeded,
+		}
+
+		Convey("Then the message reports the state of every member", func() {
+			So(err.Error(), ShouldEqual, "no primary elected in replica set rs0 (localhost:27017: STARTUP2, localhost:27018: SECONDARY): context deadline exceeded")
+		})
+	})
+}
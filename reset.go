@@ -0,0 +1,158 @@
+package mim
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ONSdigital/log.go/v2/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// systemDatabases are the databases used by MongoDB itself, which are never dropped
+var systemDatabases = map[string]bool{
+	"admin":  true,
+	"config": true,
+	"local":  true,
+}
+
+// Reset restores the server to an empty state without restarting it:
+// it drops every non-system database, removes all users and roles, turns off
+// the failpoints enabled by ConfigureFailPoint and turns off the profiler.
+// It works in both standalone and replica set modes
+//
+// Only the failpoints enabled through ConfigureFailPoint are tracked: MongoDB can not list the enabled
+// failpoints, so those enabled by running the configureFailPoint command directly, or with the
+// --setParameter failpoint.<name> server option, are left on
+func (s *Server) Reset(ctx context.Context) error {
+	client, err := s.adminClient(ctx)
+	if err != nil {
+		return err
+	}
+	admin := client.Database("admin")
+
+	if err = s.disableFailPoints(ctx, admin); err != nil {
+		return err
+	}
+
+	// Users and roles are stored in the admin database, whichever database they belong to
+	for _, cmd := range []struct{ collection, command string }{
+		{"system.users", "dropAllUsersFromDatabase"},
+		{"system.roles", "dropAllRolesFromDatabase"},
+	} {
+		dbs, err := admin.Collection(cmd.collection).Distinct(ctx, "db", bson.D{})
+		if err != nil {
+			return fmt.Errorf("could not list databases with %s: %w", cmd.collection, err)
+		}
+		for _, db := range dbs {
+			name, ok := db.(string)
+			if !ok {
+				continue
+			}
+			if err = client.Database(name).RunCommand(ctx, bson.D{{Key: cmd.command, Value: 1}}).Err(); err != nil {
+				return fmt.Errorf("could not run %s on database %s: %w", cmd.command, name, err)
+			}
+		}
+	}
+
+	dbNames, err := client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return fmt.Errorf("could not list databases: %w", err)
+	}
+
+	dropped := 0
+	for _, name := range dbNames {
+		if systemDatabases[name] && name != "admin" {
+			continue
+		}
+		profile := bson.D{{Key: "profile", Value: 0}, {Key: "slowms", Value: 100}, {Key: "sampleRate", Value: 1.0}}
+		if err = client.Database(name).RunCommand(ctx, profile).Err(); err != nil {
+			return fmt.Errorf("could not turn off the profiler on database %s: %w", name, err)
+		}
+		if systemDatabases[name] {
+			continue
+		}
+		if err = client.Database(name).Drop(ctx); err != nil {
+			return fmt.Errorf("could not drop database %s: %w", name, err)
+		}
+		dropped++
+	}
+
+	log.Info(ctx, "mongod server reset", log.Data{"dropped": dropped})
+
+	return nil
+}
+
+// ConfigureFailPoint configures the given failpoint, with the given mode (e.g. "alwaysOn", "off",
+// or bson.D{{Key: "times", Value: 1}}) and data. Failpoints enabled this way are turned off by Reset,
+// which does not know about failpoints enabled by running the configureFailPoint command directly
+func (s *Server) ConfigureFailPoint(ctx context.Context, name string, mode interface{}, data bson.D) error {
+	client, err := s.adminClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	cmd := bson.D{{Key: "configureFailPoint", Value: name}, {Key: "mode", Value: mode}}
+	if data != nil {
+		cmd = append(cmd, bson.E{Key: "data", Value: data})
+	}
+	if err = client.Database("admin").RunCommand(ctx, cmd).Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failPoints == nil {
+		s.failPoints = make(map[string]bool)
+	}
+	s.failPoints[name] = mode != "off"
+	return nil
+}
+
+// disableFailPoints turns off all the failpoints enabled by ConfigureFailPoint
+func (s *Server) disableFailPoints(ctx context.Context, admin *mongo.Database) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, enabled := range s.failPoints {
+		if !enabled {
+			continue
+		}
+		cmd := bson.D{{Key: "configureFailPoint", Value: name}, {Key: "mode", Value: "off"}}
+		if err := admin.RunCommand(ctx, cmd).Err(); err != nil {
+			return fmt.Errorf("could not turn off failpoint %s: %w", name, err)
+		}
+		s.failPoints[name] = false
+	}
+
+	return nil
+}
+
+// adminClient returns a client connected directly to the server, creating it the first time it is needed.
+// The client is disconnected when the server is stopped
+func (s *Server) adminClient(ctx context.Context) (*mongo.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil {
+		client, err := s.directClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.client = client
+	}
+	return s.client, nil
+}
+
+// disconnect disconnects the admin client, if any
+func (s *Server) disconnect(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		_ = s.client.Disconnect(ctx)
+		s.client = nil
+	}
+}
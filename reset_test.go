@@ -0,0 +1,77 @@
+package mim
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestReset(t *testing.T) {
+	testCtx := context.Background()
+
+	for name, opts := range map[string][]ServerOption{
+		"standalone":  nil,
+		"replica set": {WithReplicaSet("rs0")},
+	} {
+		Convey("Given a "+name+" server with data, users, roles, failpoints and profiling", t, func() {
+			server, err := StartWithOptions(testCtx, "5.0.2", opts...)
+			So(err, ShouldBeNil)
+			defer server.Stop(testCtx)
+
+			clientOpts := options.Client().ApplyURI(server.URI())
+			if server.ReplicaSet() != "" {
+				clientOpts.SetReplicaSet(server.ReplicaSet())
+			}
+			client, err := mongo.Connect(testCtx, clientOpts)
+			So(err, ShouldBeNil)
+			defer client.Disconnect(testCtx)
+
+			db := client.Database("test")
+			_, err = db.Collection("test").InsertOne(testCtx, bson.D{{Key: "a", Value: 1}})
+			So(err, ShouldBeNil)
+			So(db.RunCommand(testCtx, bson.D{
+				{Key: "createUser", Value: "user"}, {Key: "pwd", Value: "password"}, {Key: "roles", Value: bson.A{}},
+			}).Err(), ShouldBeNil)
+			So(db.RunCommand(testCtx, bson.D{
+				{Key: "createRole", Value: "role"}, {Key: "privileges", Value: bson.A{}}, {Key: "roles", Value: bson.A{}},
+			}).Err(), ShouldBeNil)
+			So(db.RunCommand(testCtx, bson.D{{Key: "profile", Value: 2}}).Err(), ShouldBeNil)
+			So(server.ConfigureFailPoint(testCtx, "failCommand", "alwaysOn", bson.D{
+				{Key: "failCommands", Value: bson.A{"ping"}}, {Key: "errorCode", Value: 2},
+			}), ShouldBeNil)
+
+			Convey("When Reset is called", func() {
+				err := server.Reset(testCtx)
+
+				Convey("Then the server is restored to an empty state", func() {
+					So(err, ShouldBeNil)
+
+					dbs, err := client.ListDatabaseNames(testCtx, bson.D{})
+					So(err, ShouldBeNil)
+					So(dbs, ShouldNotContain, "test")
+
+					users, err := client.Database("admin").Collection("system.users").CountDocuments(testCtx, bson.D{})
+					So(err, ShouldBeNil)
+					So(users, ShouldEqual, 0)
+
+					roles, err := client.Database("admin").Collection("system.roles").CountDocuments(testCtx, bson.D{})
+					So(err, ShouldBeNil)
+					So(roles, ShouldEqual, 0)
+
+					So(client.Ping(testCtx, nil), ShouldBeNil)
+
+					var profile struct {
+						Was int `bson:"was"`
+					}
+					So(db.RunCommand(testCtx, bson.D{{Key: "profile", Value: -1}}).Decode(&profile), ShouldBeNil)
+					So(profile.Was, ShouldEqual, 0)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package mim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ONSdigital/dp-mongodb-in-memory/download"
+	"github.com/ONSdigital/log.go/v2/log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ShardedCluster represents a running sharded MongoDB cluster: a config server replica set,
+// one or more shard replica sets and one or more mongos routers.
+type ShardedCluster struct {
+	configServers *Cluster
+	shards        []*Cluster
+	routers       []*Server
+	numShards     int
+	shardMembers  int
+	configMembers int
+	numRouters    int
+	version       *download.Version
+	collections   []ShardedCollection
+}
+
+// ShardedCollection defines a collection to be sharded, and the key to shard it by
+type ShardedCollection struct {
+	// Namespace is the full name of the collection: <database>.<collection>
+	Namespace string
+	// Key is the shard key, e.g. bson.D{{Key: "_id", Value: "hashed"}}
+	Key bson.D
+}
+
+// ShardedClusterOption defines the template function for defining options that may be used to configure the sharded cluster
+// The options available are given by the exported variables: WithShards, WithShardMembers, WithConfigServerMembers,
+// WithRouters, WithShardedCollection
+type ShardedClusterOption func(*ShardedCluster)
+
+var (
+	WithShards              = func(n int) ShardedClusterOption { return func(c *ShardedCluster) { c.numShards = n } }
+	WithShardMembers        = func(n int) ShardedClusterOption { return func(c *ShardedCluster) { c.shardMembers = n } }
+	WithConfigServerMembers = func(n int) ShardedClusterOption { return func(c *ShardedCluster) { c.configMembers = n } }
+	WithRouters             = func(n int) ShardedClusterOption { return func(c *ShardedCluster) { c.numRouters = n } }
+	WithShardedCollection   = func(ns string, key bson.D) ShardedClusterOption {
+		return func(c *ShardedCluster) {
+			c.collections = append(c.collections, ShardedCollection{Namespace: ns, Key: key})
+		}
+	}
+)
+
+// StartShardedCluster runs a sharded cluster of the given version on localhost, with 0 or more options as defined:
+// WithShards, WithShardMembers, WithConfigServerMembers, WithRouters, WithShardedCollection
+//
+// If no options are provided, the cluster is made of 2 single member shards, a single member config server
+// replica set and 1 mongos router.
+// The shards are added to the cluster and the collections given by WithShardedCollection are sharded
+// before the cluster is returned. Every process is covered by its own watcher
+func StartShardedCluster(ctx context.Context, version string, so ...ShardedClusterOption) (*ShardedCluster, error) {
+	cluster := &ShardedCluster{
+		numShards:     2,
+		shardMembers:  1,
+		configMembers: 1,
+		numRouters:    1,
+	}
+	for _, o := range so {
+		o(cluster)
+	}
+
+	if cluster.numShards < 1 || cluster.shardMembers < 1 || cluster.configMembers < 1 || cluster.numRouters < 1 {
+		return nil, errors.New("a sharded cluster needs at least one shard, config server and router, each with at least one member")
+	}
+
+	binPath, resolved, err := getOrDownloadBinPath(ctx, version, "", download.WithTools("mongos"))
+	if err != nil {
+		log.Fatal(ctx, "Could not find mongodb", err)
+		return nil, err
+	}
+	cluster.version = resolved
+
+	cluster.configServers = &Cluster{
+		size:        cluster.configMembers,
+		replSet:     "configRS",
+		clusterRole: "configsvr",
+		version:     cluster.version,
+	}
+	if err = cluster.configServers.start(ctx, binPath); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < cluster.numShards; i++ {
+		shard := &Cluster{
+			size:        cluster.shardMembers,
+			replSet:     fmt.Sprintf("shard%d", i),
+			clusterRole: "shardsvr",
+			version:     cluster.version,
+		}
+		if err = shard.start(ctx, binPath); err != nil {
+			cluster.Stop(ctx)
+			return nil, err
+		}
+		cluster.shards = append(cluster.shards, shard)
+	}
+
+	for i := 0; i < cluster.numRouters; i++ {
+		router := &Server{
+			minMongoLogLvl: LogDebug,
+			configDB:       cluster.configServers.replSet + "/" + cluster.configServers.hosts(),
+			version:        cluster.version,
+		}
+		if err = router.start(ctx, mongosPath(binPath)); err != nil {
+			router.Stop(ctx)
+			cluster.Stop(ctx)
+			return nil, err
+		}
+		cluster.routers = append(cluster.routers, router)
+	}
+
+	if err = cluster.configure(ctx); err != nil {
+		log.Error(ctx, "Could not configure the sharded cluster", err)
+		cluster.Stop(ctx)
+		return nil, err
+	}
+
+	log.Info(ctx, fmt.Sprintf("sharded cluster started up with the following configuration: %s", cluster))
+
+	return cluster, nil
+}
+
+// configure adds the shards to the cluster and shards the requested collections, through the first router
+func (c *ShardedCluster) configure(ctx context.Context) error {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(c.routers[0].URI()))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Disconnect(ctx)
+	}()
+	admin := client.Database("admin")
+
+	for _, shard := range c.shards {
+		res := admin.RunCommand(ctx, bson.D{{Key: "addShard", Value: shard.replSet + "/" + shard.hosts()}})
+		if err = res.Err(); err != nil {
+			return fmt.Errorf("could not add shard %s: %w", shard.replSet, err)
+		}
+	}
+
+	for _, coll := range c.collections {
+		db, _, found := strings.Cut(coll.Namespace, ".")
+		if !found {
+			return fmt.Errorf("invalid namespace for sharded collection: %s", coll.Namespace)
+		}
+
+		if err = admin.RunCommand(ctx, bson.D{{Key: "enableSharding", Value: db}}).Err(); err != nil {
+			return fmt.Errorf("could not enable sharding for database %s: %w", db, err)
+		}
+
+		res := admin.RunCommand(ctx, bson.D{{Key: "shardCollection", Value: coll.Namespace}, {Key: "key", Value: coll.Key}})
+		if err = res.Err(); err != nil {
+			return fmt.Errorf("could not shard collection %s: %w", coll.Namespace, err)
+		}
+	}
+
+	return nil
+}
+
+// Stop kills every router, shard and config server of the cluster.
+func (c *ShardedCluster) Stop(ctx context.Context) {
+	for _, r := range c.routers {
+		r.Stop(ctx)
+	}
+	for _, s := range c.shards {
+		s.Stop(ctx)
+	}
+	if c.configServers != nil {
+		c.configServers.Stop(ctx)
+	}
+}
+
+// Shutdown stops every router, shard and config server of the cluster gracefully, as Server.Shutdown does,
+// and returns any error found
+func (c *ShardedCluster) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, r := range c.routers {
+		if err := r.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, s := range c.shards {
+		if err := s.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.configServers != nil {
+		if err := c.configServers.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// URI returns a mongodb:// URI with the seed list of all the mongos routers
+func (c *ShardedCluster) URI() string {
+	hosts := make([]string, 0, len(c.routers))
+	for _, r := range c.routers {
+		hosts = append(hosts, fmt.Sprintf("localhost:%d", r.Port()))
+	}
+	return "mongodb://" + strings.Join(hosts, ",")
+}
+
+// Routers returns the mongos routers of the cluster
+func (c *ShardedCluster) Routers() []*Server {
+	return c.routers
+}
+
+// Shards returns the replica sets acting as shards of the cluster
+func (c *ShardedCluster) Shards() []*Cluster {
+	return c.shards
+}
+
+// ConfigServers returns the config server replica set of the cluster
+func (c *ShardedCluster) ConfigServers() *Cluster {
+	return c.configServers
+}
+
+func (c *ShardedCluster) String() string {
+	buf := new(strings.Builder)
+	_, _ = fmt.Fprintf(buf, "config servers: %s;", c.configServers.hosts())
+	for _, s := range c.shards {
+		_, _ = fmt.Fprintf(buf, " shard %s: %s;", s.replSet, s.hosts())
+	}
+	_, _ = fmt.Fprintf(buf, " routers: %s", strings.TrimPrefix(c.URI(), "mongodb://"))
+
+	return buf.String()
+}
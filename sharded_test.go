@@ -0,0 +1,95 @@
+package mim
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestStartShardedCluster(t *testing.T) {
+	versions := []string{"4.4.8", "5.0.2"}
+	testCtx := context.Background()
+
+	for _, version := range versions {
+		Convey("Given the version "+version, t, func() {
+
+			Convey("When the StartShardedCluster method is called with a sharded collection", func() {
+				cluster, err := StartShardedCluster(testCtx, version,
+					WithShards(2),
+					WithRouters(2),
+					WithShardedCollection("test.sharded", bson.D{{Key: "_id", Value: "hashed"}}),
+				)
+				defer cluster.Stop(testCtx)
+
+				Convey("Then no error is returned", func() {
+					So(err, ShouldBeNil)
+					Convey("And the config servers, shards and routers have run", func() {
+						So(cluster.ConfigServers().Members(), ShouldHaveLength, 1)
+						So(cluster.ConfigServers().Members()[0].cmd.Args, ShouldContain, "--configsvr")
+						So(cluster.Shards(), ShouldHaveLength, 2)
+						for _, shard := range cluster.Shards() {
+							So(shard.Members(), ShouldHaveLength, 1)
+							So(shard.Members()[0].cmd.Args, ShouldContain, "--shardsvr")
+						}
+						So(cluster.Routers(), ShouldHaveLength, 2)
+						for _, router := range cluster.Routers() {
+							So(router.cmd.Args[0], ShouldEndWith, "mongos")
+							So(router.watcherCmd, ShouldNotBeNil)
+						}
+
+						Convey("And the collection is sharded across the shards", func() {
+							client, err := mongo.Connect(testCtx, options.Client().ApplyURI(cluster.URI()))
+							So(err, ShouldBeNil)
+							defer client.Disconnect(testCtx)
+
+							var shards []bson.M
+							cursor, err := client.Database("config").Collection("shards").Find(testCtx, bson.D{})
+							So(err, ShouldBeNil)
+							So(cursor.All(testCtx, &shards), ShouldBeNil)
+							So(shards, ShouldHaveLength, 2)
+
+							count, err := client.Database("config").Collection("collections").CountDocuments(testCtx, bson.D{{Key: "_id", Value: "test.sharded"}})
+							So(err, ShouldBeNil)
+							So(count, ShouldEqual, 1)
+
+							_, err = client.Database("test").Collection("sharded").InsertOne(testCtx, bson.D{{Key: "a", Value: 1}})
+							So(err, ShouldBeNil)
+						})
+					})
+				})
+			})
+		})
+	}
+}
+
+func TestServerArgs(t *testing.T) {
+	Convey("Given a shard server", t, func() {
+		server := &Server{port: 27017, dbDir: "/tmp/db", replSet: "shard0", clusterRole: "shardsvr", storageEngine: StorageEngineWiredTiger}
+
+		Convey("Then its arguments include the cluster role", func() {
+			So(server.args(), ShouldResemble, []string{
+				"--bind_ip", "localhost", "--port", "27017", "--dbpath", "/tmp/db",
+				"--storageEngine", "wiredTiger", "--replSet", "shard0", "--shardsvr",
+				"--wiredTigerCacheSizeGB", "0.25",
+				"--setParameter", "enableTestCommands=1",
+			})
+			So(server.processName(), ShouldEqual, "mongod")
+		})
+	})
+
+	Convey("Given a mongos router", t, func() {
+		server := &Server{port: 27017, configDB: "configRS/localhost:27018"}
+
+		Convey("Then its arguments include the config servers and no database directory", func() {
+			So(server.args(), ShouldResemble, []string{
+				"--bind_ip", "localhost", "--port", "27017", "--configdb", "configRS/localhost:27018",
+			})
+			So(server.processName(), ShouldEqual, "mongos")
+		})
+	})
+}